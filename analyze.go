@@ -0,0 +1,297 @@
+package main
+
+import (
+	"fmt"
+	"regexp/syntax"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	IssueUnsatisfiable       = "UNSATISFIABLE"
+	IssueOverlappingCost     = "OVERLAPPING_COST"
+	IssueUnreachableDiscount = "UNREACHABLE_DISCOUNT"
+)
+
+// Analysis lists problems found in the rules of a product.
+type Analysis struct {
+	Issues []Issue `json:"issues"`
+}
+
+// Issue describes product rules or prices of a component that can never work as intended.
+// Prices are indexes in the component price list. Conditions is an example
+// condition set under which the problem shows up.
+type Issue struct {
+	Type       string      `json:"type"`
+	Component  string      `json:"component,omitempty"`
+	Prices     []int       `json:"prices,omitempty"`
+	CodeName   string      `json:"codeName,omitempty"`
+	Conditions []Condition `json:"conditions,omitempty"`
+	Message    string      `json:"message"`
+}
+
+// Result of the search for a condition value meeting a set of rules.
+type satisfiability int
+
+const (
+	satisfiable satisfiability = iota
+	unsatisfiable
+	undetermined
+)
+
+// Analyze inspects the product for prices that can never match,
+// COST prices that can match at the same time and discounts
+// that never apply together with any COST price.
+// The analysis assumes conditions are given for every codeName used by the prices.
+func Analyze(product *Product) *Analysis {
+	analysis := &Analysis{Issues: []Issue{}}
+	compiled := Compile(product)
+	if compiled == nil {
+		return analysis
+	}
+
+	if codeName, ok := compiled.rules.unsatisfiable(); ok {
+		analysis.Issues = append(analysis.Issues, Issue{
+			Type:     IssueUnsatisfiable,
+			CodeName: codeName,
+			Message:  fmt.Sprintf("no value of %s meets all rules of the product", codeName),
+		})
+	}
+
+	for _, component := range compiled.components {
+		analysis.Issues = append(analysis.Issues, component.analyze()...)
+	}
+
+	return analysis
+}
+
+// Function analyzes prices of the component.
+func (c *compiledComponent) analyze() []Issue {
+	var issues []Issue
+	var costs, discounts []int
+
+	for i := range c.prices {
+		if codeName, ok := c.prices[i].rules.unsatisfiable(); ok {
+			issues = append(issues, Issue{
+				Type:      IssueUnsatisfiable,
+				Component: c.name,
+				Prices:    []int{i},
+				CodeName:  codeName,
+				Message:   fmt.Sprintf("no value of %s meets all rules of the price", codeName),
+			})
+			continue
+		}
+
+		switch c.prices[i].priceType {
+		case PriceTypeCost:
+			costs = append(costs, i)
+		case PriceTypeDiscount:
+			discounts = append(discounts, i)
+		}
+	}
+
+	for n, i := range costs {
+		for _, j := range costs[n+1:] {
+			conditions, result := solvePrices(&c.prices[i], &c.prices[j])
+			if result != satisfiable {
+				continue
+			}
+			issues = append(issues, Issue{
+				Type:       IssueOverlappingCost,
+				Component:  c.name,
+				Prices:     []int{i, j},
+				Conditions: conditions,
+				Message:    "both COST prices match the same conditions, the component will be rejected",
+			})
+		}
+	}
+
+	for _, i := range discounts {
+		reachable := false
+		for _, j := range costs {
+			if _, result := solvePrices(&c.prices[i], &c.prices[j]); result != unsatisfiable {
+				reachable = true
+				break
+			}
+		}
+		if !reachable {
+			issues = append(issues, Issue{
+				Type:      IssueUnreachableDiscount,
+				Component: c.name,
+				Prices:    []int{i},
+				Message:   "the discount never matches together with a COST price",
+			})
+		}
+	}
+
+	return issues
+}
+
+// Function returns the codeName whose rules can never be met.
+func (r ruleIndex) unsatisfiable() (string, bool) {
+	for _, name := range sortedKeys(r) {
+		if _, result := solveRules(r[name]); result == unsatisfiable {
+			return r[name][0].codeName, true
+		}
+	}
+	return "", false
+}
+
+// Function searches for conditions under which all prices match.
+func solvePrices(prices ...*compiledPrice) ([]Condition, satisfiability) {
+	rules := make(map[string][]compiledRule)
+	for _, price := range prices {
+		for name, r := range price.rules {
+			rules[name] = append(rules[name], r...)
+		}
+	}
+
+	var conditions []Condition
+	status := satisfiable
+	for _, name := range sortedKeys(rules) {
+		value, result := solveRules(rules[name])
+		switch result {
+		case unsatisfiable:
+			return nil, unsatisfiable
+		case undetermined:
+			status = undetermined
+		default:
+			conditions = append(conditions, Condition{RuleName: rules[name][0].codeName, Value: value})
+		}
+	}
+
+	if status != satisfiable {
+		return nil, status
+	}
+	return conditions, satisfiable
+}
+
+// Function searches for a single condition value meeting all rules.
+// Unsatisfiable is returned only when it is proven, rules that cannot be
+// solved by the simple candidate search are reported as undetermined.
+func solveRules(rules []compiledRule) (string, satisfiability) {
+	var equal, prefix, suffix string
+	var contains, patterns []string
+	var lo, hi float64
+	var hasEqual, hasLo, hasHi bool
+
+	for _, rule := range rules {
+		if rule.err != nil {
+			return "", unsatisfiable
+		}
+
+		switch rule.operator {
+		case OperatorEqual:
+			if hasEqual && equal != rule.value {
+				return "", unsatisfiable
+			}
+			equal, hasEqual = rule.value, true
+		case OperatorGreaterThanOrEqual:
+			if !hasLo || rule.number > lo {
+				lo, hasLo = rule.number, true
+			}
+		case OperatorLessThanOrEqual:
+			if !hasHi || rule.number < hi {
+				hi, hasHi = rule.number, true
+			}
+		case OperatorStartsWith:
+			if !strings.HasPrefix(prefix, rule.value) {
+				if !strings.HasPrefix(rule.value, prefix) {
+					return "", unsatisfiable
+				}
+				prefix = rule.value
+			}
+		case OperatorEndsWith:
+			if !strings.HasSuffix(suffix, rule.value) {
+				if !strings.HasSuffix(rule.value, suffix) {
+					return "", unsatisfiable
+				}
+				suffix = rule.value
+			}
+		case OperatorContains:
+			contains = append(contains, rule.value)
+		case OperatorMatches:
+			patterns = append(patterns, rule.value)
+		default:
+			return "", unsatisfiable
+		}
+	}
+
+	if hasLo && hasHi && lo > hi {
+		return "", unsatisfiable
+	}
+
+	var candidates []string
+	if hasEqual {
+		candidates = []string{equal}
+	} else {
+		if hasLo {
+			candidates = append(candidates, strconv.FormatFloat(lo, 'f', -1, 64))
+		}
+		if hasHi {
+			candidates = append(candidates, strconv.FormatFloat(hi, 'f', -1, 64))
+		}
+		candidates = append(candidates, prefix+strings.Join(contains, "")+suffix)
+		for _, pattern := range patterns {
+			candidates = append(candidates, patternSample(pattern))
+		}
+	}
+
+	for _, candidate := range candidates {
+		if met, _ := checkRules(rules, compileCondition("", candidate)); met {
+			return candidate, satisfiable
+		}
+	}
+
+	if hasEqual || (len(contains)+len(patterns) == 0 && prefix+suffix == "") {
+		return "", unsatisfiable
+	}
+	return "", undetermined
+}
+
+// Function builds a short string matching the regular expression.
+func patternSample(pattern string) string {
+	re, err := syntax.Parse(pattern, syntax.Perl)
+	if err != nil {
+		return ""
+	}
+
+	var b strings.Builder
+	writeSample(&b, re.Simplify())
+	return b.String()
+}
+
+func writeSample(b *strings.Builder, re *syntax.Regexp) {
+	switch re.Op {
+	case syntax.OpLiteral:
+		b.WriteString(string(re.Rune))
+	case syntax.OpCharClass:
+		if len(re.Rune) > 0 {
+			b.WriteRune(re.Rune[0])
+		}
+	case syntax.OpAnyChar, syntax.OpAnyCharNotNL:
+		b.WriteByte('a')
+	case syntax.OpCapture, syntax.OpPlus:
+		writeSample(b, re.Sub[0])
+	case syntax.OpRepeat:
+		for i := 0; i < re.Min; i++ {
+			writeSample(b, re.Sub[0])
+		}
+	case syntax.OpConcat:
+		for _, sub := range re.Sub {
+			writeSample(b, sub)
+		}
+	case syntax.OpAlternate:
+		writeSample(b, re.Sub[0])
+	}
+}
+
+func sortedKeys(rules ruleIndex) []string {
+	keys := make([]string, 0, len(rules))
+	for key := range rules {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+
+	"go-rti-testing/pkg/errors"
+)
+
+const (
+	defaultBatchWorkers = 8
+	defaultBatchMax     = 100
+)
+
+// BatchResult is the outcome of one request of a batch: an offer, the reason
+// why there is no offer or an error with the status the request would get alone.
+type BatchResult struct {
+	ID     string      `json:"id,omitempty" yaml:"id,omitempty"`
+	Offer  *Offer      `json:"offer,omitempty" yaml:"offer,omitempty"`
+	Reason string      `json:"reason,omitempty" yaml:"reason,omitempty"`
+	Error  *BatchError `json:"error,omitempty" yaml:"error,omitempty"`
+}
+
+type BatchError struct {
+	Status  int    `json:"status" yaml:"status"`
+	Message string `json:"message" yaml:"message"`
+}
+
+func (s *server) batch(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		httpError(w, errors.MethodNotAllowed.New(""))
+		return
+	}
+
+	quote := false
+	if value := req.URL.Query().Get("quote"); value != "" {
+		var err error
+		if quote, err = strconv.ParseBool(value); err != nil {
+			httpError(w, errors.BadRequest.Newf("invalid quote parameter: %s", value))
+			return
+		}
+	}
+
+	var requests []CalculateRequest
+	if err := decodeBody(req, &requests); err != nil {
+		httpError(w, err)
+		return
+	}
+	if len(requests) > s.batchMax {
+		httpError(w, errors.BadRequest.Newf("batch has %d requests, maximum is %d", len(requests), s.batchMax))
+		return
+	}
+
+	if err := encodeBody(w, req, s.calculateBatch(requests, quote)); err != nil {
+		httpError(w, err)
+	}
+}
+
+// Function calculates the requests concurrently by a bounded number of workers
+// and issues quotes for the offers if asked. Results keep the order of the requests.
+func (s *server) calculateBatch(requests []CalculateRequest, quote bool) []BatchResult {
+	results := make([]BatchResult, len(requests))
+	jobs := make(chan int)
+
+	workers := s.batchWorkers
+	if workers > len(requests) {
+		workers = len(requests)
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				results[j] = s.calculateBatchItem(&requests[j], quote)
+			}
+		}()
+	}
+
+	for i := range requests {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	return results
+}
+
+func (s *server) calculateBatchItem(calcReq *CalculateRequest, quote bool) BatchResult {
+	offer, reason, err := s.calculateRequest(calcReq, quote)
+	if err != nil {
+		status, message := errorStatus(err)
+		if message == "" {
+			message = http.StatusText(status)
+		}
+		return BatchResult{ID: calcReq.ID, Error: &BatchError{Status: status, Message: message}}
+	}
+	return BatchResult{ID: calcReq.ID, Offer: offer, Reason: reason}
+}
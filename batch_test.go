@@ -0,0 +1,110 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCalculateBatch(t *testing.T) {
+	catalogs, err := NewCatalogStore(writeCatalog(t, map[string]interface{}{"gaming.json": product}), NewMemoryStorage())
+	require.NoError(t, err)
+	srv := newServer(catalogs, nil)
+	srv.batchWorkers = 2
+	srv.batchMax = 50
+	handler := srv.routes()
+
+	serve := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/calculate/batch", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := serve(`[
+		{"id":"adsl","productId":"gaming","conditions":[{"ruleName":"technology","value":"adsl"},{"ruleName":"internetSpeed","value":"10"}]},
+		{"id":"unknown","productId":"unknown","conditions":[]},
+		{"id":"none","productId":"gaming","conditions":[{"ruleName":"technology","value":"dialup"}]},
+		{"id":"invalid","productId":"gaming","conditions":[{"ruleName":"internetSpeed","value":"fast"}]}
+	]`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	var results []BatchResult
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &results))
+	require.Len(t, results, 4)
+
+	assert.Equal(t, "adsl", results[0].ID)
+	require.NotNil(t, results[0].Offer)
+	assert.Equal(t, 400.0, results[0].Offer.TotalCost.Cost)
+
+	assert.Equal(t, "unknown", results[1].ID)
+	assert.Equal(t, &BatchError{Status: http.StatusNotFound, Message: "unknown product: unknown"}, results[1].Error)
+
+	assert.Equal(t, "none", results[2].ID)
+	assert.Nil(t, results[2].Offer)
+	assert.NotEmpty(t, results[2].Reason)
+
+	assert.Equal(t, "invalid", results[3].ID)
+	require.NotNil(t, results[3].Error)
+	assert.Equal(t, http.StatusBadRequest, results[3].Error.Status)
+
+	// Results keep the order of the requests.
+	items := make([]string, 50)
+	for i := range items {
+		items[i] = fmt.Sprintf(`{"id":"%d","productId":"gaming","conditions":[{"ruleName":"technology","value":"adsl"},{"ruleName":"internetSpeed","value":"10"}]}`, i)
+	}
+	rec = serve("[" + strings.Join(items, ",") + "]")
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &results))
+	require.Len(t, results, 50)
+	for i, result := range results {
+		assert.Equal(t, fmt.Sprint(i), result.ID)
+	}
+
+	rec = serve("[" + strings.Join(append(items, items[0]), ",") + "]")
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = serve("[]")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "[]\n", rec.Body.String())
+}
+
+func TestCalculateBatchQuotes(t *testing.T) {
+	catalogs, err := NewCatalogStore(writeCatalog(t, map[string]interface{}{"gaming.json": product}), NewMemoryStorage())
+	require.NoError(t, err)
+	storage := NewMemoryStorage()
+	handler := newServer(catalogs, NewQuoteStore(storage, time.Hour, nil)).routes()
+
+	serve := func(path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader("["+fmt.Sprintf(streamLine, "adsl")+"]"))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	var results []BatchResult
+	rec := serve("/calculate/batch")
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &results))
+	require.NotNil(t, results[0].Offer)
+	assert.Empty(t, results[0].Offer.QuoteID)
+	assert.Empty(t, storage.quotes)
+
+	rec = serve("/calculate/batch?quote=true")
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &results))
+	require.NotNil(t, results[0].Offer)
+	assert.NotEmpty(t, results[0].Offer.QuoteID)
+	assert.Len(t, storage.quotes, 1)
+
+	assert.Equal(t, http.StatusBadRequest, serve("/calculate/batch?quote=maybe").Code)
+}
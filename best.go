@@ -0,0 +1,43 @@
+package main
+
+import (
+	"sort"
+)
+
+// BestOffers lists offers ranked by total cost and the products without an offer.
+type BestOffers struct {
+	Offers   []*Offer    `json:"offers"`
+	Rejected []Rejection `json:"rejected"`
+}
+
+// Rejection explains why the product has no offer.
+type Rejection struct {
+	ProductID string `json:"productId,omitempty"`
+	Product   string `json:"product"`
+	Reason    string `json:"reason"`
+}
+
+// Best calculates every product under the same conditions
+// and ranks the offers from the cheapest one.
+func Best(products []*CompiledProduct, conditions []Condition) *BestOffers {
+	best := &BestOffers{Offers: []*Offer{}, Rejected: []Rejection{}}
+
+	for _, product := range products {
+		offer, reason, err := product.calculate(conditions, nil)
+		if err != nil {
+			reason = err.Error()
+		}
+
+		if offer == nil {
+			best.Rejected = append(best.Rejected, Rejection{ProductID: product.id, Product: product.name, Reason: reason})
+			continue
+		}
+		best.Offers = append(best.Offers, offer)
+	}
+
+	sort.SliceStable(best.Offers, func(i, j int) bool {
+		return best.Offers[i].TotalCost.Cost < best.Offers[j].TotalCost.Cost
+	})
+
+	return best
+}
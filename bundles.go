@@ -0,0 +1,68 @@
+package main
+
+import (
+	"math"
+)
+
+// Function applies the bundle discounts whose components are all in the offer.
+// Discounts with targets lower the cost of the target components,
+// the others lower the total cost.
+func (p *CompiledProduct) applyBundles(offer *Offer) {
+	for _, bundle := range p.bundles {
+		if !offerContains(offer, bundle.Components) {
+			continue
+		}
+
+		var saved float64
+		if len(bundle.Targets) == 0 {
+			cost := bundle.apply(offer.TotalCost.Cost)
+			saved = offer.TotalCost.Cost - cost
+			offer.TotalCost.Cost = cost
+		} else {
+			for i := range offer.Components {
+				if !containsString(bundle.Targets, offer.Components[i].Name) {
+					continue
+				}
+				price := &offer.Components[i].Prices[0]
+				cost := bundle.apply(price.Cost)
+				saved += price.Cost - cost
+				offer.TotalCost.Cost -= price.Cost - cost
+				price.Cost = cost
+			}
+		}
+
+		offer.TotalCost.Cost = roundCost(offer.TotalCost.Cost)
+		offer.AppliedBundles = append(offer.AppliedBundles, AppliedBundle{
+			Name:  bundle.Name,
+			Saved: roundCost(saved),
+		})
+	}
+}
+
+// Function returns the cost lowered by the bundle.
+func (b *BundleDiscount) apply(cost float64) float64 {
+	if b.Discount > 0 {
+		cost = discountedCost(cost, b.Discount)
+	}
+	return roundCost(math.Max(cost-b.Amount, 0))
+}
+
+func roundCost(cost float64) float64 {
+	return math.Round(cost*100) / 100
+}
+
+func offerContains(offer *Offer, names []string) bool {
+	for _, name := range names {
+		found := false
+		for i := range offer.Components {
+			if offer.Components[i].Name == name {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
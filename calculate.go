@@ -1,112 +1,312 @@
 package main
 
 import (
+	"fmt"
 	"math"
-	"strconv"
 	"strings"
 
 	"go-rti-testing/pkg/errors"
 )
 
-func Calculate(product *Product, conditions []Condition) (offer *Offer, err error) {
-	if product == nil {
+// Maximum number of single-valued condition sets evaluated for one calculation.
+const maxConditionCombinations = 1000
+
+// Calculate returns the cheapest offer available under the conditions.
+// Multi-valued conditions are expanded into every combination of values,
+// so the result is the best offer given everything the conditions allow.
+func Calculate(product *Product, conditions []Condition) (*Offer, error) {
+	return Compile(product).Calculate(conditions)
+}
+
+// Calculate works like the package-level Calculate on the compiled product.
+func (p *CompiledProduct) Calculate(conditions []Condition) (*Offer, error) {
+	offer, _, err := p.calculate(conditions, nil)
+	return offer, err
+}
+
+// Function calculates the best offer with the selected components. If there is no offer,
+// then returns the reason why the first condition set was rejected.
+func (p *CompiledProduct) calculate(conditions []Condition, components *ComponentSelection) (offer *Offer, reason string, err error) {
+	if p == nil {
 		return
 	}
 
-	totalCost, components, err := componentSearch(product.Components, conditions)
-	if err != nil || totalCost == nil {
-		return
+	sets, err := expandConditions(conditions)
+	if err != nil {
+		return nil, "", err
+	}
+
+	selected, err := p.newSelection(components)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var selectionErr error
+	for _, set := range sets {
+		candidate, rejected, err := p.calculateOffer(set, selected)
+		if sErr, ok := err.(selectionError); ok {
+			if selectionErr == nil {
+				selectionErr = sErr.error
+			}
+			continue
+		}
+		if err != nil {
+			return nil, "", err
+		}
+
+		if candidate == nil && reason == "" {
+			reason = rejected
+		}
+		if candidate != nil && (offer == nil || candidate.TotalCost.Cost < offer.TotalCost.Cost) {
+			offer = candidate
+		}
+	}
+
+	if offer != nil {
+		return offer, "", nil
+	}
+	if selectionErr != nil {
+		return nil, "", selectionErr
+	}
+	return nil, reason, nil
+}
+
+// Function calculates the offer for single-valued conditions.
+// If there is no offer, then returns the reason.
+func (p *CompiledProduct) calculateOffer(conditions []compiledCondition, selected *selection) (*Offer, string, error) {
+	match, err := p.rules.check(conditions)
+	if err != nil || !match {
+		return nil, "product rules are not met", err
+	}
+
+	components, reason, err := p.componentSearch(conditions, selected)
+	if err != nil || reason != "" {
+		return nil, reason, err
+	}
+
+	// Groups choose from the components left after resolving dependencies,
+	// then the dependencies are resolved again for the members not chosen.
+	components, excluded, reason, err := resolveDependencies(components, selected)
+	if err != nil || reason != "" {
+		return nil, reason, err
+	}
+	components, grouped := p.applyGroups(components)
+	components, unresolved, reason, err := resolveDependencies(components, selected)
+	if err != nil || reason != "" {
+		return nil, reason, err
+	}
+	if reason := p.checkGroups(components); reason != "" {
+		return nil, reason, nil
+	}
+	if reason := p.checkMainComponents(components); reason != "" {
+		return nil, reason, nil
+	}
+	excluded = append(append(excluded, grouped...), unresolved...)
+
+	offer := &Offer{Version: p.version, Excluded: excluded}
+	offer.Product.ID = p.id
+	offer.Product.Name = p.name
+	for _, c := range components {
+		offer.Product.Components = append(offer.Product.Components,
+			Component{
+				Name:   c.component.name,
+				IsMain: c.component.isMain,
+				Prices: []Price{{Cost: c.cost}},
+			})
+		offer.TotalCost.Cost += c.cost
+	}
+	p.applyBundles(offer)
+
+	return offer, "", nil
+}
+
+// Function merges conditions with the same rule name and expands
+// multi-valued conditions into all combinations of single values.
+func expandConditions(conditions []Condition) ([][]compiledCondition, error) {
+	var merged [][]compiledCondition
+	index := make(map[string]int)
+
+	for _, condition := range conditions {
+		name := strings.ToLower(condition.RuleName)
+		i, ok := index[name]
+		if !ok {
+			i = len(merged)
+			index[name] = i
+			merged = append(merged, nil)
+		}
+
+		for _, value := range condition.values() {
+			if !containsValue(merged[i], value) {
+				merged[i] = append(merged[i], compileCondition(name, value))
+			}
+		}
+	}
+
+	combinations := 1
+	for _, values := range merged {
+		combinations *= len(values)
+		if combinations > maxConditionCombinations {
+			return nil, errors.BadRequest.Newf("too many condition combinations, maximum is %d", maxConditionCombinations)
+		}
+	}
+
+	sets := [][]compiledCondition{make([]compiledCondition, 0, len(merged))}
+	for _, values := range merged {
+		expanded := make([][]compiledCondition, 0, len(sets)*len(values))
+		for _, set := range sets {
+			for _, value := range values {
+				next := make([]compiledCondition, len(set), len(merged))
+				copy(next, set)
+				expanded = append(expanded, append(next, value))
+			}
+		}
+		sets = expanded
+	}
+
+	return sets, nil
+}
+
+// Function returns all values of the condition.
+func (c Condition) values() []string {
+	if len(c.Values) == 0 {
+		return []string{c.Value}
+	}
+	if c.Value == "" {
+		return c.Values
+	}
+
+	return append([]string{c.Value}, c.Values...)
+}
+
+func containsValue(conditions []compiledCondition, value string) bool {
+	for _, condition := range conditions {
+		if condition.value == value {
+			return true
+		}
+	}
+	return false
+}
+
+// Function checks that at least one main component is in the offer
+// when the product requires any of them.
+func (p *CompiledProduct) checkMainComponents(components []offeredComponent) string {
+	if !p.anyMain {
+		return ""
+	}
+
+	hasMain := false
+	for i := range p.components {
+		if p.components[i].isMain {
+			hasMain = true
+			break
+		}
+	}
+	if !hasMain {
+		return ""
 	}
 
-	offer = &Offer{TotalCost: *totalCost}
-	offer.Product.Name = product.Name
-	offer.Product.Components = components
+	for i := range components {
+		if components[i].component.isMain {
+			return ""
+		}
+	}
+	return "none of the main components is available"
+}
 
-	return offer, nil
+// Component chosen for the offer with its discounted cost.
+type offeredComponent struct {
+	component *compiledComponent
+	cost      float64
 }
 
-// Function searches for suitable components and calculates total cost.
-func componentSearch(components []Component, conditions []Condition) (*Price, []Component, error) {
-	var totalCost float64
-	var relevant []Component
+// Function searches for suitable components.
+// If a main component is not valid, then returns the reason instead.
+func (p *CompiledProduct) componentSearch(conditions []compiledCondition, selected *selection) ([]offeredComponent, string, error) {
+	var relevant []offeredComponent
+
+	for i := range p.components {
+		component := &p.components[i]
+		if !selected.offered(component) {
+			continue
+		}
 
-	for _, component := range components {
-		valid, cost, err := validateComponent(component, conditions)
+		price, cost, err := component.validate(conditions)
 		if err != nil {
-			return new(Price), nil, err
+			return nil, "", err
 		}
 
-		if !valid {
-			if component.IsMain {
-				return nil, nil, nil
+		if price < 0 {
+			if component.mandatory {
+				return nil, fmt.Sprintf("main component %s is not available", component.name), nil
+			}
+			if selected.included(component) {
+				return nil, "", selectionError{errors.BadRequest.Newf("component %s is not available", component.name)}
 			}
 			continue
 		}
 
-		relevant = append(relevant,
-			Component{
-				Name:   component.Name,
-				IsMain: component.IsMain,
-				Prices: []Price{{Cost: cost}},
-			})
-		totalCost += cost
+		relevant = append(relevant, offeredComponent{component: component, cost: cost})
 	}
 
-	return &Price{Cost: totalCost}, relevant, nil
+	return relevant, "", nil
 }
 
-// Function checks the component and returns the discounted cost.
-func validateComponent(component Component, conditions []Condition) (bool, float64, error) {
+// Function checks the component and returns the index of the selected
+// COST price with the discounted cost. Index is -1 if the component is not valid.
+func (c *compiledComponent) validate(conditions []compiledCondition) (int, float64, error) {
 	var cost, discount float64
+	selected := -1
 
-	for _, price := range component.Prices {
-		match, err := check(price.RuleApplicabilities, conditions)
+	for i, price := range c.prices {
+		match, err := price.rules.check(conditions)
 		if err != nil {
-			return false, 0, err
+			return -1, 0, err
 		}
 
 		if !match {
 			continue
 		}
 
-		switch strings.ToUpper(price.PriceType) {
+		switch price.priceType {
 		case PriceTypeCost:
 			if cost > 0 {
-				return false, 0, nil
+				return -1, 0, nil
 			}
-			cost = price.Cost
+			cost = price.cost
+			selected = i
 		case PriceTypeDiscount:
-			if discount < price.Cost {
-				discount = price.Cost
+			if discount < price.cost {
+				discount = price.cost
 			}
 		}
 	}
 
 	if cost == 0 {
-		return false, 0, nil
+		return -1, 0, nil
 	}
 
-	return true, discountedCost(cost, discount), nil
+	return selected, discountedCost(cost, discount), nil
 }
 
-// Function checks conditions according to selected rules.
+// Function checks conditions according to the rules.
 // If there are no conditions or all conditions are met, then returns true.
-func check(rules []RuleApplicability, conditions []Condition) (bool, error) {
-	if len(conditions) == 0 {
-		return true, nil
+func (r ruleIndex) check(conditions []compiledCondition) (bool, error) {
+	for _, condition := range conditions {
+		met, err := checkRules(r[condition.name], condition)
+		if err != nil || !met {
+			return false, err
+		}
 	}
 
-	ruleMap := make(map[string]RuleApplicability)
-	for _, rule := range rules {
-		ruleMap[strings.ToLower(rule.CodeName)] = rule
-	}
+	return true, nil
+}
 
-	for _, condition := range conditions {
-		if rule, ok := ruleMap[strings.ToLower(condition.RuleName)]; ok {
-			met, err := conditionCheckByRule(condition, rule)
-			if err != nil || !met {
-				return false, err
-			}
+// Function checks the condition against all rules of its codeName.
+func checkRules(rules []compiledRule, condition compiledCondition) (bool, error) {
+	for i := range rules {
+		met, err := rules[i].conditionCheck(condition)
+		if err != nil || !met {
+			return false, err
 		}
 	}
 
@@ -115,54 +315,37 @@ func check(rules []RuleApplicability, conditions []Condition) (bool, error) {
 
 // Function performs the condition check by the rule.
 // If the condition is fulfilled, then returns true.
-func conditionCheckByRule(condition Condition, rule RuleApplicability) (bool, error) {
-	switch rule.Operator {
+func (r *compiledRule) conditionCheck(condition compiledCondition) (bool, error) {
+	switch r.operator {
 	case OperatorEqual:
-		return rule.Value == condition.Value, nil
-	case OperatorLessThanOrEqual:
-		return lessThanOrEqual(condition.Value, rule.Value)
-	case OperatorGreaterThanOrEqual:
-		return greaterThanOrEqual(condition.Value, rule.Value)
+		return r.value == condition.value, nil
+	case OperatorLessThanOrEqual, OperatorGreaterThanOrEqual:
+		if condition.numberErr != nil {
+			return false, condition.numberErr
+		}
+		if r.err != nil {
+			return false, r.err
+		}
+		if r.operator == OperatorLessThanOrEqual {
+			return condition.number <= r.number, nil
+		}
+		return condition.number >= r.number, nil
+	case OperatorMatches:
+		if r.err != nil {
+			return false, r.err
+		}
+		return r.pattern.MatchString(condition.value), nil
+	case OperatorStartsWith:
+		return strings.HasPrefix(condition.value, r.value), nil
+	case OperatorEndsWith:
+		return strings.HasSuffix(condition.value, r.value), nil
+	case OperatorContains:
+		return strings.Contains(condition.value, r.value), nil
 	default:
 		return false, nil
 	}
 }
 
-// v1 <= v2
-func lessThanOrEqual(v1, v2 string) (bool, error) {
-	v1f, v2f, err := parseValues(v1, v2)
-	if err != nil {
-		return false, err
-	}
-
-	return v1f <= v2f, nil
-}
-
-// v1 >= v2
-func greaterThanOrEqual(v1, v2 string) (bool, error) {
-	v1f, v2f, err := parseValues(v1, v2)
-	if err != nil {
-		return false, err
-	}
-
-	return v1f >= v2f, nil
-}
-
-// string to float64
-func parseValues(a, b string) (float64, float64, error) {
-	af, err := strconv.ParseFloat(a, 64)
-	if err != nil {
-		return 0, 0, errors.BadRequest.Newf("invalid float value: %s", a)
-	}
-
-	bf, err := strconv.ParseFloat(b, 64)
-	if err != nil {
-		return 0, 0, errors.BadRequest.Newf("invalid float value: %s", b)
-	}
-
-	return af, bf, nil
-}
-
 // Calculate discounted cost
 func discountedCost(cost, discount float64) float64 {
 	if discount > 100 {
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"go-rti-testing/pkg/errors"
+)
+
+// Catalog is an immutable set of validated products keyed by ID.
+// It is safe for concurrent use.
+type Catalog struct {
+	products map[string]*catalogProduct
+}
+
+type catalogProduct struct {
+	product  *Product
+	compiled *CompiledProduct
+	hash     string
+	file     string
+}
+
+// LoadCatalog loads every *.json, *.yaml and *.yml file of the directory as a product
+// and every *.csv file as a set of products in the CSV layout.
+// The ID of a JSON or YAML product defaults to the file name without the extension.
+func LoadCatalog(dir string) (*Catalog, error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, errors.Wrapf(err, "read catalog %s", dir)
+	}
+
+	catalog := &Catalog{products: make(map[string]*catalogProduct)}
+	for _, file := range files {
+		if file.IsDir() || !isCatalogFile(file.Name()) {
+			continue
+		}
+
+		var products []*Product
+		path := filepath.Join(dir, file.Name())
+		switch ext := filepath.Ext(file.Name()); ext {
+		case ".csv":
+			if products, err = loadCSV(path); err != nil {
+				return nil, err
+			}
+		default:
+			product, err := loadProduct(path)
+			if err != nil {
+				return nil, err
+			}
+			if product.ID == "" {
+				product.ID = strings.TrimSuffix(file.Name(), ext)
+			}
+			products = append(products, product)
+		}
+
+		for _, product := range products {
+			if _, ok := catalog.products[product.ID]; ok {
+				return nil, errors.Wrapf(errors.New("duplicate product id "+product.ID), "catalog file %s", file.Name())
+			}
+
+			hash, err := productHash(product)
+			if err != nil {
+				return nil, errors.Wrapf(err, "catalog file %s", file.Name())
+			}
+			catalog.products[product.ID] = &catalogProduct{product: product, compiled: Compile(product), hash: hash, file: file.Name()}
+		}
+	}
+
+	return catalog, nil
+}
+
+func isCatalogFile(name string) bool {
+	switch filepath.Ext(name) {
+	case ".json", ".csv", ".yaml", ".yml":
+		return true
+	}
+	return false
+}
+
+// Function reads and validates the product file.
+func loadProduct(path string) (*Product, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "read catalog file %s", path)
+	}
+
+	product := new(Product)
+	if isYAMLFile(filepath.Ext(path)) {
+		product, err = UnmarshalProductYAML(data)
+	} else {
+		err = json.Unmarshal(data, product)
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "decode catalog file %s", path)
+	}
+	if err := ValidateProduct(product); err != nil {
+		return nil, errors.Wrapf(err, "catalog file %s", path)
+	}
+
+	return product, nil
+}
+
+// Function reads the products of the CSV file.
+func loadCSV(path string) ([]*Product, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "read catalog file %s", path)
+	}
+	defer file.Close()
+
+	products, err := ImportCSV(file)
+	if err != nil {
+		return nil, errors.Wrapf(err, "catalog file %s", path)
+	}
+
+	result := make([]*Product, 0, len(products))
+	for i := range products {
+		result = append(result, &products[i])
+	}
+	return result, nil
+}
+
+// Function returns the SHA-256 of the product JSON.
+func productHash(product *Product) (string, error) {
+	data, err := json.Marshal(product)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// Product returns the compiled product by ID.
+func (c *Catalog) Product(id string) (*CompiledProduct, error) {
+	if c != nil {
+		if p, ok := c.products[id]; ok {
+			return p.compiled, nil
+		}
+	}
+	return nil, errors.NotFound.Newf("unknown product: %s", id)
+}
+
+// IDs returns the sorted product IDs.
+func (c *Catalog) IDs() []string {
+	if c == nil {
+		return nil
+	}
+
+	ids := make([]string, 0, len(c.products))
+	for id := range c.products {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
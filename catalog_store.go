@@ -0,0 +1,197 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// CatalogStore holds the current catalog of a directory and reloads it.
+// A new catalog replaces the current one only when it loads and validates
+// completely, otherwise the current catalog keeps being served.
+// Every product change is kept as a new immutable product version in the storage.
+type CatalogStore struct {
+	dir     string
+	current atomic.Value
+	mu      sync.Mutex // serializes reloads
+	files   string     // fingerprint of the last loaded files
+	writeMu sync.Mutex // serializes product writes
+
+	storage   Storage
+	readOnly  bool // new versions are kept in memory only
+	historyMu sync.RWMutex
+	history   map[string][]*productVersion
+	now       func() time.Time
+}
+
+// CatalogChanges lists product IDs changed by a reload.
+type CatalogChanges struct {
+	Added   []string
+	Removed []string
+	Changed []string
+}
+
+// NewCatalogStore restores the version history from the storage
+// and loads the catalog of the directory.
+func NewCatalogStore(dir string, storage Storage) (*CatalogStore, error) {
+	s := &CatalogStore{dir: dir, storage: storage, now: time.Now}
+	if err := s.loadVersions(); err != nil {
+		return nil, err
+	}
+	if _, err := s.Reload(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// NewReadOnlyCatalogStore restores the version history from the storage and never
+// writes to it. The catalog is loaded from the directory, and versions of products
+// changed since the stored ones are kept in memory only. Without a directory
+// the catalog is made of the last stored versions.
+func NewReadOnlyCatalogStore(dir string, storage Storage) (*CatalogStore, error) {
+	s := &CatalogStore{dir: dir, storage: storage, readOnly: true, now: time.Now}
+	if err := s.loadVersions(); err != nil {
+		return nil, err
+	}
+	if dir == "" {
+		s.current.Store(s.lastCatalog())
+		return s, nil
+	}
+	if _, err := s.Reload(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Catalog returns the current catalog.
+func (s *CatalogStore) Catalog() *Catalog {
+	if s == nil {
+		return nil
+	}
+	catalog, _ := s.current.Load().(*Catalog)
+	return catalog
+}
+
+// Reload loads the directory again and swaps the catalog in.
+func (s *CatalogStore) Reload() (*CatalogChanges, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	files, err := catalogFiles(s.dir)
+	if err != nil {
+		return nil, err
+	}
+	s.files = files
+
+	catalog, err := LoadCatalog(s.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := catalog.changesSince(s.Catalog())
+	if err := s.recordVersions(catalog); err != nil {
+		return nil, err
+	}
+	s.current.Store(catalog)
+	return changes, nil
+}
+
+// Watch reloads the catalog whenever files of the directory change
+// and logs the result. It returns when the context is done.
+func (s *CatalogStore) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		files, err := catalogFiles(s.dir)
+		if err != nil {
+			log.Printf("ERROR watch catalog: %v", err)
+			continue
+		}
+
+		s.mu.Lock()
+		changed := files != s.files
+		s.mu.Unlock()
+		if changed {
+			s.ReloadAndLog()
+		}
+	}
+}
+
+// ReloadAndLog reloads the catalog and logs the changes or the error.
+func (s *CatalogStore) ReloadAndLog() {
+	changes, err := s.Reload()
+	if err != nil {
+		log.Printf("ERROR reload catalog, keeping the current one: %v", err)
+		return
+	}
+	log.Printf("Reloaded catalog %s: %s", s.dir, changes)
+}
+
+func (c *CatalogChanges) String() string {
+	if len(c.Added)+len(c.Removed)+len(c.Changed) == 0 {
+		return "no changes"
+	}
+
+	var parts []string
+	for _, p := range []struct {
+		name string
+		ids  []string
+	}{{"added", c.Added}, {"removed", c.Removed}, {"changed", c.Changed}} {
+		if len(p.ids) > 0 {
+			parts = append(parts, fmt.Sprintf("%s %s", p.name, strings.Join(p.ids, ", ")))
+		}
+	}
+	return strings.Join(parts, "; ")
+}
+
+// Function compares product hashes with the previous catalog.
+func (c *Catalog) changesSince(old *Catalog) *CatalogChanges {
+	changes := &CatalogChanges{}
+	for _, id := range c.IDs() {
+		var previous *catalogProduct
+		if old != nil {
+			previous = old.products[id]
+		}
+		switch {
+		case previous == nil:
+			changes.Added = append(changes.Added, id)
+		case previous.hash != c.products[id].hash:
+			changes.Changed = append(changes.Changed, id)
+		}
+	}
+	for _, id := range old.IDs() {
+		if _, ok := c.products[id]; !ok {
+			changes.Removed = append(changes.Removed, id)
+		}
+	}
+	return changes
+}
+
+// Function returns a fingerprint of the catalog files: names, sizes and modification times.
+func catalogFiles(dir string) (string, error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	for _, file := range files {
+		if file.IsDir() || !isCatalogFile(file.Name()) {
+			continue
+		}
+		fmt.Fprintf(&b, "%s %d %d\n", file.Name(), file.Size(), file.ModTime().UnixNano())
+	}
+	return b.String(), nil
+}
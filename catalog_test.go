@@ -0,0 +1,231 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Function writes the products into a temporary catalog directory.
+func writeCatalog(t *testing.T, products map[string]interface{}) string {
+	dir, err := ioutil.TempDir("", "catalog")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+
+	for name, product := range products {
+		writeCatalogFile(t, dir, name, product)
+	}
+	return dir
+}
+
+func writeCatalogFile(t *testing.T, dir, name string, product interface{}) {
+	data, err := json.Marshal(product)
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, name), data, 0644))
+}
+
+func TestLoadCatalog(t *testing.T) {
+	dir := writeCatalog(t, map[string]interface{}{
+		"gaming.json": product,
+		"notes.txt":   "not a product",
+	})
+
+	catalog, err := LoadCatalog(dir)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"gaming"}, catalog.IDs())
+
+	compiled, err := catalog.Product("gaming")
+	require.NoError(t, err)
+	offer, err := compiled.Calculate([]Condition{
+		{RuleName: "technology", Value: "xpon"},
+		{RuleName: "internetSpeed", Value: "200"},
+	})
+	require.NoError(t, err)
+	require.NotNil(t, offer)
+	assert.Equal(t, "gaming", offer.ID)
+	assert.Equal(t, 765.0, offer.TotalCost.Cost)
+
+	_, err = catalog.Product("unknown")
+	assert.Error(t, err)
+}
+
+func TestLoadCatalogInvalid(t *testing.T) {
+	invalid := Product{
+		Name: "Сломанный",
+		Components: []Component{
+			{
+				Name: "Интернет",
+				Prices: []Price{
+					{
+						Cost:      100,
+						PriceType: PriceTypeCost,
+						RuleApplicabilities: []RuleApplicability{
+							{CodeName: "internetSpeed", Operator: OperatorGreaterThanOrEqual, Value: "fast"},
+						},
+					},
+				},
+			},
+		},
+	}
+	dir := writeCatalog(t, map[string]interface{}{
+		"gaming.json": product,
+		"broken.json": invalid,
+	})
+
+	_, err := LoadCatalog(dir)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "broken.json")
+	assert.Contains(t, err.Error(), "invalid float value: fast")
+}
+
+func TestCalculateByProductID(t *testing.T) {
+	catalogs, err := NewCatalogStore(writeCatalog(t, map[string]interface{}{"gaming.json": product}), NewMemoryStorage())
+	require.NoError(t, err)
+	handler := newServer(catalogs, nil).routes()
+
+	tests := []struct {
+		body   string
+		status int
+	}{
+		{`{"productId":"gaming","conditions":[{"ruleName":"technology","value":"adsl"},{"ruleName":"internetSpeed","value":"10"}]}`, http.StatusOK},
+		{`{"productId":"unknown","conditions":[]}`, http.StatusNotFound},
+		{`{"productId":"gaming","product":{"name":"Игровой"},"conditions":[]}`, http.StatusBadRequest},
+		{`{"conditions":[]}`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPost, "/calculate", strings.NewReader(tt.body))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		assert.Equal(t, tt.status, rec.Code, tt.body)
+		if tt.status == http.StatusOK {
+			var offer Offer
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &offer))
+			assert.Equal(t, "gaming", offer.ID)
+			assert.Equal(t, 400.0, offer.TotalCost.Cost)
+		}
+	}
+}
+
+func TestCatalogStoreReload(t *testing.T) {
+	dir := writeCatalog(t, map[string]interface{}{
+		"gaming.json": product,
+		"home.json":   Product{Name: "Домашний", Components: product.Components},
+	})
+	catalogs, err := NewCatalogStore(dir, NewMemoryStorage())
+	require.NoError(t, err)
+	assert.Equal(t, []string{"gaming", "home"}, catalogs.Catalog().IDs())
+
+	changed := product
+	changed.Name = "Игровой+"
+	writeCatalogFile(t, dir, "gaming.json", changed)
+	writeCatalogFile(t, dir, "office.json", Product{Name: "Офисный", Components: product.Components})
+	require.NoError(t, os.Remove(filepath.Join(dir, "home.json")))
+
+	changes, err := catalogs.Reload()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"office"}, changes.Added)
+	assert.Equal(t, []string{"home"}, changes.Removed)
+	assert.Equal(t, []string{"gaming"}, changes.Changed)
+	assert.Equal(t, "added office; removed home; changed gaming", changes.String())
+
+	// An invalid file keeps the current catalog.
+	current := catalogs.Catalog()
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "broken.json"), []byte("{"), 0644))
+	_, err = catalogs.Reload()
+	require.Error(t, err)
+	assert.Same(t, current, catalogs.Catalog())
+}
+
+func TestCatalogStoreWatch(t *testing.T) {
+	dir := writeCatalog(t, map[string]interface{}{"gaming.json": product})
+	catalogs, err := NewCatalogStore(dir, NewMemoryStorage())
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go catalogs.Watch(ctx, 10*time.Millisecond)
+
+	writeCatalogFile(t, dir, "home.json", Product{Name: "Домашний", Components: product.Components})
+	assert.Eventually(t, func() bool {
+		_, err := catalogs.Catalog().Product("home")
+		return err == nil
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestCatalogVersions(t *testing.T) {
+	dir := writeCatalog(t, map[string]interface{}{"gaming.json": product})
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	catalogs := &CatalogStore{dir: dir, storage: NewMemoryStorage(), now: func() time.Time { return start }}
+	require.NoError(t, catalogs.loadVersions())
+	_, err := catalogs.Reload()
+	require.NoError(t, err)
+
+	catalogs.now = func() time.Time { return start.Add(24 * time.Hour) }
+	cheaper := product
+	cheaper.Components = append([]Component{}, product.Components...)
+	cheaper.Components[0].Prices = []Price{{Cost: 50, PriceType: PriceTypeCost}}
+	writeCatalogFile(t, dir, "gaming.json", cheaper)
+	_, err = catalogs.Reload()
+	require.NoError(t, err)
+
+	// Unchanged products keep the version.
+	_, err = catalogs.Reload()
+	require.NoError(t, err)
+
+	catalogs.now = func() time.Time { return start.Add(48 * time.Hour) }
+	require.NoError(t, os.Remove(filepath.Join(dir, "gaming.json")))
+	_, err = catalogs.Reload()
+	require.NoError(t, err)
+
+	versions, err := catalogs.Versions("gaming")
+	require.NoError(t, err)
+	require.Len(t, versions, 3)
+	assert.Equal(t, 2, versions[1].Version)
+	assert.Nil(t, versions[2].Product)
+
+	handler := newServer(catalogs, nil).routes()
+	conditions := `"conditions":[{"ruleName":"technology","value":"adsl"},{"ruleName":"internetSpeed","value":"10"}]`
+	tests := []struct {
+		body    string
+		status  int
+		version int
+		cost    float64
+	}{
+		{`{"productId":"gaming","version":1,` + conditions + `}`, http.StatusOK, 1, 400},
+		{`{"productId":"gaming","version":2,` + conditions + `}`, http.StatusOK, 2, 350},
+		{`{"productId":"gaming","asOf":"2020-01-01T12:00:00Z",` + conditions + `}`, http.StatusOK, 1, 400},
+		{`{"productId":"gaming","asOf":"2020-01-02T12:00:00Z",` + conditions + `}`, http.StatusOK, 2, 350},
+		{`{"productId":"gaming","asOf":"2020-01-03T12:00:00Z",` + conditions + `}`, http.StatusNotFound, 0, 0},
+		{`{"productId":"gaming",` + conditions + `}`, http.StatusNotFound, 0, 0},
+		{`{"productId":"gaming","version":3,` + conditions + `}`, http.StatusNotFound, 0, 0},
+		{`{"productId":"gaming","version":4,` + conditions + `}`, http.StatusNotFound, 0, 0},
+		{`{"productId":"gaming","version":1,"asOf":"2020-01-01T12:00:00Z",` + conditions + `}`, http.StatusBadRequest, 0, 0},
+		{`{"product":{"name":"Игровой"},"version":1,` + conditions + `}`, http.StatusBadRequest, 0, 0},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPost, "/calculate", strings.NewReader(tt.body))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		assert.Equal(t, tt.status, rec.Code, tt.body)
+		if tt.status == http.StatusOK {
+			var offer Offer
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &offer))
+			assert.Equal(t, tt.version, offer.Version, tt.body)
+			assert.Equal(t, tt.cost, offer.TotalCost.Cost, tt.body)
+		}
+	}
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+
+	"go-rti-testing/pkg/errors"
+)
+
+// Product IDs are used as file names of the catalog.
+var productIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-][A-Za-z0-9._-]*$`)
+
+// StoredProduct is a catalog product with its entity tag.
+type StoredProduct struct {
+	Product *Product
+	ETag    string
+}
+
+// Get returns the current product with its entity tag.
+func (c *Catalog) Get(id string) (*StoredProduct, error) {
+	if c != nil {
+		if p, ok := c.products[id]; ok {
+			return &StoredProduct{Product: p.product, ETag: p.hash}, nil
+		}
+	}
+	return nil, errors.NotFound.Newf("unknown product: %s", id)
+}
+
+// Create adds a new product to the catalog directory.
+func (s *CatalogStore) Create(product *Product) (*StoredProduct, error) {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	if err := validateProductID(product.ID); err != nil {
+		return nil, err
+	}
+	if _, err := s.Catalog().Get(product.ID); err == nil {
+		return nil, errors.Conflict.Newf("product %s already exists", product.ID)
+	}
+
+	file, err := s.newFile(product.ID)
+	if err != nil {
+		return nil, err
+	}
+	return s.write(product, file)
+}
+
+// Put creates or replaces the product. A non-empty ifMatch must be
+// the entity tag of the current product or "*" for any existing product.
+func (s *CatalogStore) Put(product *Product, ifMatch string) (stored *StoredProduct, created bool, err error) {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	if err := validateProductID(product.ID); err != nil {
+		return nil, false, err
+	}
+
+	current, ok := s.Catalog().products[product.ID]
+	if err := checkETag(current, ifMatch); err != nil {
+		return nil, false, err
+	}
+	if !ok {
+		file, err := s.newFile(product.ID)
+		if err != nil {
+			return nil, false, err
+		}
+		stored, err := s.write(product, file)
+		return stored, true, err
+	}
+
+	if err := checkWritable(current); err != nil {
+		return nil, false, err
+	}
+	stored, err = s.write(product, current.file)
+	return stored, false, err
+}
+
+// Delete removes the product from the catalog directory.
+func (s *CatalogStore) Delete(id string, ifMatch string) error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	current, ok := s.Catalog().products[id]
+	if !ok {
+		return errors.NotFound.Newf("unknown product: %s", id)
+	}
+	if err := checkETag(current, ifMatch); err != nil {
+		return err
+	}
+	if err := checkWritable(current); err != nil {
+		return err
+	}
+
+	path := filepath.Join(s.dir, current.file)
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return errors.Internal.Wrapf(err, "read catalog file %s", path)
+	}
+	if err := os.Remove(path); err != nil {
+		return errors.Internal.Wrapf(err, "remove catalog file %s", path)
+	}
+
+	if _, err := s.Reload(); err != nil {
+		if rErr := writeFile(path, data); rErr != nil {
+			return errors.Internal.Wrapf(rErr, "restore catalog file %s", path)
+		}
+		return errors.Internal.Wrapf(err, "reload catalog")
+	}
+	return nil
+}
+
+// Function validates the product, writes it into the file atomically
+// and reloads the catalog. If the reload fails, then the file is restored.
+// YAML files keep their comments.
+func (s *CatalogStore) write(product *Product, file string) (*StoredProduct, error) {
+	if err := ValidateProduct(product); err != nil {
+		return nil, err
+	}
+
+	path := filepath.Join(s.dir, file)
+	previous, err := ioutil.ReadFile(path)
+	existed := err == nil
+	if err != nil && !os.IsNotExist(err) {
+		return nil, errors.Internal.Wrapf(err, "read catalog file %s", path)
+	}
+
+	var data []byte
+	if isYAMLFile(filepath.Ext(file)) {
+		data, err = MarshalProductYAML(product, previous)
+	} else {
+		data, err = json.MarshalIndent(product, "", "  ")
+	}
+	if err != nil {
+		return nil, errors.Internal.Wrap(err, "encode product")
+	}
+	if err := writeFile(path, data); err != nil {
+		return nil, errors.Internal.Wrapf(err, "write catalog file %s", path)
+	}
+
+	if _, err := s.Reload(); err != nil {
+		var rErr error
+		if existed {
+			rErr = writeFile(path, previous)
+		} else {
+			rErr = os.Remove(path)
+		}
+		if rErr != nil {
+			return nil, errors.Internal.Wrapf(rErr, "restore catalog file %s", path)
+		}
+		return nil, errors.Internal.Wrapf(err, "reload catalog")
+	}
+
+	return s.Catalog().Get(product.ID)
+}
+
+// Function replaces the file with the data through a temporary file,
+// so the catalog never sees a partially written product.
+func writeFile(path string, data []byte) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(path), ".product-*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Function returns the file name for a new product.
+func (s *CatalogStore) newFile(id string) (string, error) {
+	file := id + ".json"
+	if _, err := os.Stat(filepath.Join(s.dir, file)); !os.IsNotExist(err) {
+		return "", errors.Conflict.Newf("catalog file %s already exists", file)
+	}
+	return file, nil
+}
+
+// Function checks that the product has a file of its own.
+// Products of a CSV file are changed by editing the file.
+func checkWritable(current *catalogProduct) error {
+	if filepath.Ext(current.file) == ".csv" {
+		return errors.Conflict.Newf("product %s is defined in %s and cannot be changed through the API", current.product.ID, current.file)
+	}
+	return nil
+}
+
+func validateProductID(id string) error {
+	if !productIDPattern.MatchString(id) {
+		return errors.BadRequest.Newf("invalid product id: %q", id)
+	}
+	return nil
+}
+
+// Function checks the If-Match value against the current product.
+func checkETag(current *catalogProduct, ifMatch string) error {
+	switch {
+	case ifMatch == "":
+		return nil
+	case current == nil:
+		return errors.PreconditionFailed.New("product does not exist")
+	case ifMatch != "*" && ifMatch != current.hash:
+		return errors.PreconditionFailed.New("product has been changed")
+	}
+	return nil
+}
@@ -0,0 +1,286 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Function runs the calculate subcommand and returns the exit code:
+// 0 if there is an offer, 1 if there is none and 2 on errors.
+func runCalculate(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("calculate", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	catalogDir, dataFile := offlineFlags(flags)
+	asYAML := flags.Bool("yaml", false, "print the offer as YAML")
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: go-rti-testing calculate [-catalog DIR] [-data FILE] [-yaml] [REQUEST]")
+		fmt.Fprintln(stderr, "REQUEST is a JSON or YAML file of a calculate request, stdin if it is missing or -.")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() > 1 {
+		flags.Usage()
+		return 2
+	}
+
+	data, err := readInput(flags.Arg(0), stdin)
+	if err != nil {
+		fmt.Fprintf(stderr, "calculate: %v\n", err)
+		return 2
+	}
+	var calcReq CalculateRequest
+	if err := unmarshalRequest(data, &calcReq); err != nil {
+		fmt.Fprintf(stderr, "calculate: %v\n", err)
+		return 2
+	}
+
+	srv, storage, err := newOfflineServer(*catalogDir, *dataFile)
+	if err != nil {
+		fmt.Fprintf(stderr, "calculate: %v\n", err)
+		return 2
+	}
+	defer storage.Close()
+
+	offer, reason, err := srv.calculateRequest(&calcReq, false)
+	if err != nil {
+		fmt.Fprintf(stderr, "calculate: %v\n", err)
+		return 2
+	}
+	if offer == nil {
+		fmt.Fprintf(stderr, "calculate: no offer: %s\n", reason)
+		return 1
+	}
+
+	if *asYAML {
+		err = yaml.NewEncoder(stdout).Encode(offer)
+	} else {
+		encoder := json.NewEncoder(stdout)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(offer)
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "calculate: %v\n", err)
+		return 2
+	}
+	return 0
+}
+
+// Function runs the replay subcommand, which calculates a JSON lines file of calculate
+// requests like POST /calculate/stream, and returns the exit code:
+// 0 if every request was calculated, 1 if some results are errors and 2 on other errors.
+func runReplay(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("replay", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	catalogDir, dataFile := offlineFlags(flags)
+	workers := flags.Int("workers", defaultBatchWorkers, "number of concurrent calculations")
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: go-rti-testing replay [-catalog DIR] [-data FILE] [-workers N] [REQUESTS]")
+		fmt.Fprintln(stderr, "REQUESTS is a JSON lines file of calculate requests, stdin if it is missing or -.")
+		fmt.Fprintln(stderr, "A JSON line with the result of every request is printed in the same order.")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() > 1 || *workers < 1 {
+		flags.Usage()
+		return 2
+	}
+
+	input := stdin
+	if name := flags.Arg(0); name != "" && name != "-" {
+		file, err := os.Open(name)
+		if err != nil {
+			fmt.Fprintf(stderr, "replay: %v\n", err)
+			return 2
+		}
+		defer file.Close()
+		input = file
+	}
+
+	srv, storage, err := newOfflineServer(*catalogDir, *dataFile)
+	if err != nil {
+		fmt.Fprintf(stderr, "replay: %v\n", err)
+		return 2
+	}
+	defer storage.Close()
+	srv.batchWorkers = *workers
+
+	failed := false
+	encoder := json.NewEncoder(stdout)
+	err = srv.calculateStream(input, func(result *BatchResult) error {
+		if result.Error != nil {
+			failed = true
+		}
+		return encoder.Encode(result)
+	})
+	if err != nil {
+		fmt.Fprintf(stderr, "replay: %v\n", err)
+		return 2
+	}
+
+	if failed {
+		return 1
+	}
+	return 0
+}
+
+// Function adds the flags of the catalog and the storage to an offline subcommand.
+func offlineFlags(flags *flag.FlagSet) (catalogDir, dataFile *string) {
+	catalogDir = flags.String("catalog", "", "directory of product JSON, YAML and CSV files for requests with productId")
+	dataFile = flags.String("data", "", "storage file of catalog versions for requests with version or asOf, it is only read")
+	return catalogDir, dataFile
+}
+
+// Function returns a server calculating like the HTTP one, but without issuing quotes.
+// The storage is opened read-only, so new catalog versions are kept in memory
+// and a running server can keep writing to the same file.
+// Without the catalog directory the last stored versions are used.
+// The storage must be closed by the caller.
+func newOfflineServer(catalogDir, dataFile string) (*server, Storage, error) {
+	var storage Storage = NewMemoryStorage()
+	if dataFile != "" {
+		var err error
+		if storage, err = OpenFileStorageReadOnly(dataFile); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	var catalogs *CatalogStore
+	if catalogDir != "" || dataFile != "" {
+		var err error
+		if catalogs, err = NewReadOnlyCatalogStore(catalogDir, storage); err != nil {
+			storage.Close()
+			return nil, nil, err
+		}
+	}
+	return newServer(catalogs, nil), storage, nil
+}
+
+// Function reads the file or stdin if the name is empty or -.
+func readInput(name string, stdin io.Reader) ([]byte, error) {
+	if name == "" || name == "-" {
+		return ioutil.ReadAll(stdin)
+	}
+	return ioutil.ReadFile(name)
+}
+
+// Function decodes a JSON object or a YAML document.
+func unmarshalRequest(data []byte, dst interface{}) error {
+	var err error
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) {
+		err = json.Unmarshal(data, dst)
+	} else {
+		err = yaml.Unmarshal(data, dst)
+	}
+	if err != nil {
+		return fmt.Errorf("decode request: %v", err)
+	}
+	return nil
+}
+
+// Function runs the diff subcommand and returns the exit code:
+// 0 if the product sets are equal, 1 if they differ and 2 on errors.
+func runDiff(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("diff", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	asJSON := flags.Bool("json", false, "print the differences as JSON")
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: go-rti-testing diff [-json] OLD NEW")
+		fmt.Fprintln(stderr, "OLD and NEW are catalog directories or product files (.json, .yaml, .yml, .csv).")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 2 {
+		flags.Usage()
+		return 2
+	}
+
+	old, err := loadProducts(flags.Arg(0))
+	if err != nil {
+		fmt.Fprintf(stderr, "diff: %v\n", err)
+		return 2
+	}
+	new, err := loadProducts(flags.Arg(1))
+	if err != nil {
+		fmt.Fprintf(stderr, "diff: %v\n", err)
+		return 2
+	}
+
+	diff, err := DiffProducts(old, new)
+	if err != nil {
+		fmt.Fprintf(stderr, "diff: %v\n", err)
+		return 2
+	}
+
+	if *asJSON {
+		encoder := json.NewEncoder(stdout)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(diff)
+	} else {
+		err = diff.WriteText(stdout)
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "diff: %v\n", err)
+		return 2
+	}
+
+	if diff.Empty() {
+		return 0
+	}
+	return 1
+}
+
+// Function loads the products of a catalog directory or of a single product file.
+func loadProducts(path string) ([]Product, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if info.IsDir() {
+		catalog, err := LoadCatalog(path)
+		if err != nil {
+			return nil, err
+		}
+		products := make([]Product, 0, len(catalog.IDs()))
+		for _, id := range catalog.IDs() {
+			products = append(products, *catalog.products[id].product)
+		}
+		return products, nil
+	}
+
+	if filepath.Ext(path) == ".csv" {
+		products, err := loadCSV(path)
+		if err != nil {
+			return nil, err
+		}
+		result := make([]Product, 0, len(products))
+		for _, product := range products {
+			result = append(result, *product)
+		}
+		return result, nil
+	}
+
+	product, err := loadProduct(path)
+	if err != nil {
+		return nil, err
+	}
+	if product.ID == "" {
+		product.ID = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	return []Product{*product}, nil
+}
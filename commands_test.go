@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunCalculate(t *testing.T) {
+	dir := writeCatalog(t, map[string]interface{}{"gaming.json": product})
+
+	var stdout, stderr bytes.Buffer
+	stdin := strings.NewReader(fmt.Sprintf(streamLine, "adsl"))
+	require.Equal(t, 0, runCalculate([]string{"-catalog", dir}, stdin, &stdout, &stderr), stderr.String())
+	var offer Offer
+	require.NoError(t, json.Unmarshal(stdout.Bytes(), &offer))
+	assert.Equal(t, 400.0, offer.TotalCost.Cost)
+	assert.Empty(t, offer.QuoteID)
+
+	file := filepath.Join(writeCatalog(t, nil), "request.yaml")
+	require.NoError(t, ioutil.WriteFile(file, []byte(strings.Join([]string{
+		"productId: gaming",
+		"conditions:",
+		"  - ruleName: technology",
+		"    value: dialup",
+	}, "\n")), 0644))
+	stdout.Reset()
+	assert.Equal(t, 1, runCalculate([]string{"-catalog", dir, file}, nil, &stdout, &stderr))
+	assert.Empty(t, stdout.String())
+
+	assert.Equal(t, 2, runCalculate([]string{file}, nil, &stdout, &stderr))
+	assert.Contains(t, stderr.String(), "unknown product: gaming")
+	assert.Equal(t, 2, runCalculate([]string{"-catalog", dir}, strings.NewReader("{"), &stdout, &stderr))
+}
+
+func TestRunReplay(t *testing.T) {
+	dir := writeCatalog(t, map[string]interface{}{"gaming.json": product})
+	requests := strings.Join([]string{
+		fmt.Sprintf(streamLine, "first"),
+		fmt.Sprintf(streamLine, "second"),
+	}, "\n")
+
+	var stdout, stderr bytes.Buffer
+	code := runReplay([]string{"-catalog", dir, "-workers", "1"}, strings.NewReader(requests), &stdout, &stderr)
+	require.Equal(t, 0, code, stderr.String())
+	lines := strings.Split(strings.TrimSuffix(stdout.String(), "\n"), "\n")
+	require.Len(t, lines, 2)
+	for i, id := range []string{"first", "second"} {
+		var result BatchResult
+		require.NoError(t, json.Unmarshal([]byte(lines[i]), &result))
+		assert.Equal(t, id, result.ID)
+		require.NotNil(t, result.Offer)
+		assert.Equal(t, 400.0, result.Offer.TotalCost.Cost)
+	}
+
+	stdout.Reset()
+	code = runReplay(nil, strings.NewReader(fmt.Sprintf(streamLine, "first")), &stdout, &stderr)
+	assert.Equal(t, 1, code)
+	var result BatchResult
+	require.NoError(t, json.Unmarshal(stdout.Bytes(), &result))
+	assert.Equal(t, &BatchError{Status: http.StatusNotFound, Message: "unknown product: gaming"}, result.Error)
+
+	assert.Equal(t, 2, runReplay([]string{filepath.Join(dir, "missing.jsonl")}, nil, &stdout, &stderr))
+	assert.Equal(t, 2, runReplay([]string{"-workers", "0"}, nil, &stdout, &stderr))
+}
+
+func TestOfflineCommandsReadOnlyStorage(t *testing.T) {
+	dir := writeCatalog(t, map[string]interface{}{"gaming.json": product})
+	path := filepath.Join(writeCatalog(t, nil), "data.jsonl")
+	storage, err := OpenFileStorage(path)
+	require.NoError(t, err)
+	_, err = NewCatalogStore(dir, storage)
+	require.NoError(t, err)
+	require.NoError(t, storage.Close())
+	stored, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+
+	changed := product
+	changed.Components = append([]Component(nil), product.Components...)
+	changed.Components[0].Prices = []Price{{Cost: 150, PriceType: PriceTypeCost}}
+	writeCatalogFile(t, dir, "gaming.json", changed)
+
+	calculate := func(args []string, request string) *Offer {
+		var stdout, stderr bytes.Buffer
+		require.Equal(t, 0, runCalculate(args, strings.NewReader(request), &stdout, &stderr), stderr.String())
+		var offer Offer
+		require.NoError(t, json.Unmarshal(stdout.Bytes(), &offer))
+		return &offer
+	}
+
+	offer := calculate([]string{"-catalog", dir, "-data", path}, fmt.Sprintf(streamLine, "adsl"))
+	assert.Equal(t, 450.0, offer.TotalCost.Cost)
+	assert.Equal(t, 2, offer.Version)
+
+	// Without the directory the last stored versions are priced.
+	offer = calculate([]string{"-data", path}, fmt.Sprintf(streamLine, "adsl"))
+	assert.Equal(t, 400.0, offer.TotalCost.Cost)
+	offer = calculate([]string{"-data", path},
+		`{"productId":"gaming","version":1,"conditions":[{"ruleName":"technology","value":"adsl"},{"ruleName":"internetSpeed","value":"10"}]}`)
+	assert.Equal(t, 1, offer.Version)
+
+	var stdout, stderr bytes.Buffer
+	require.Equal(t, 0, runReplay([]string{"-catalog", dir, "-data", path}, strings.NewReader(fmt.Sprintf(streamLine, "adsl")), &stdout, &stderr), stderr.String())
+
+	data, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, string(stored), string(data))
+
+	assert.Equal(t, 2, runCalculate([]string{"-data", filepath.Join(dir, "missing.jsonl")}, strings.NewReader(fmt.Sprintf(streamLine, "adsl")), &stdout, &stderr))
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"go-rti-testing/pkg/errors"
+)
+
+// CompiledProduct is an immutable evaluation structure built from a Product.
+// It is safe for concurrent use and is meant to be reused across requests.
+type CompiledProduct struct {
+	id         string
+	name       string
+	version    int
+	rules      ruleIndex
+	anyMain    bool
+	components []compiledComponent
+	groups     []compiledGroup
+	bundles    []BundleDiscount
+}
+
+// Component is mandatory when it is main and the product requires all main components.
+type compiledComponent struct {
+	name      string
+	isMain    bool
+	mandatory bool
+	requires  []string
+	excludes  []string
+	prices    []compiledPrice
+}
+
+// Rules indexed by lowercase codeName.
+// All rules with the same codeName must be met.
+type ruleIndex map[string][]compiledRule
+
+type compiledPrice struct {
+	cost      float64
+	priceType string
+	rules     ruleIndex
+}
+
+// Rule with the value prepared for its operator.
+// Parse errors are kept and reported only when the rule is evaluated.
+type compiledRule struct {
+	codeName string
+	operator string
+	value    string
+	number   float64
+	pattern  *regexp.Regexp
+	err      error
+}
+
+// Single-valued condition with the lowercase rule name and the parsed value.
+type compiledCondition struct {
+	name      string
+	value     string
+	number    float64
+	numberErr error
+}
+
+// Compile prepares the product for repeated calculations.
+func Compile(product *Product) *CompiledProduct {
+	if product == nil {
+		return nil
+	}
+
+	compiled := &CompiledProduct{
+		id:         product.ID,
+		name:       product.Name,
+		anyMain:    strings.ToUpper(product.MainComponents) == MainComponentsAny,
+		components: make([]compiledComponent, 0, len(product.Components)),
+	}
+
+	patterns := make(map[string]compiledRule)
+	compiled.rules = compileRules(product.RuleApplicabilities, patterns)
+	for _, component := range product.Components {
+		c := compiledComponent{
+			name:      component.Name,
+			isMain:    component.IsMain,
+			mandatory: component.IsMain && !compiled.anyMain,
+			requires:  component.Requires,
+			excludes:  component.Excludes,
+			prices:    make([]compiledPrice, 0, len(component.Prices)),
+		}
+
+		for _, price := range component.Prices {
+			p := compiledPrice{
+				cost:      price.Cost,
+				priceType: strings.ToUpper(price.PriceType),
+				rules:     compileRules(price.RuleApplicabilities, patterns),
+			}
+			c.prices = append(c.prices, p)
+		}
+
+		compiled.components = append(compiled.components, c)
+	}
+
+	compiled.bundles = append(compiled.bundles, product.Bundles...)
+
+	for _, group := range product.Groups {
+		compiled.groups = append(compiled.groups, compiledGroup{
+			name:       group.Name,
+			components: group.Components,
+			min:        group.Min,
+			max:        group.Max,
+		})
+	}
+
+	return compiled
+}
+
+// Function indexes the rules by lowercase codeName.
+func compileRules(rules []RuleApplicability, patterns map[string]compiledRule) ruleIndex {
+	index := make(ruleIndex, len(rules))
+	for _, rule := range rules {
+		name := strings.ToLower(rule.CodeName)
+		index[name] = append(index[name], compileRule(rule, patterns))
+	}
+	return index
+}
+
+// Function prepares the rule value. Regular expressions are shared
+// between rules with the same pattern.
+func compileRule(rule RuleApplicability, patterns map[string]compiledRule) compiledRule {
+	compiled := compiledRule{codeName: rule.CodeName, operator: rule.Operator, value: rule.Value}
+
+	switch rule.Operator {
+	case OperatorLessThanOrEqual, OperatorGreaterThanOrEqual:
+		compiled.number, compiled.err = parseFloat(rule.Value)
+	case OperatorMatches:
+		if cached, ok := patterns[rule.Value]; ok {
+			cached.codeName = rule.CodeName
+			return cached
+		}
+		compiled.pattern, compiled.err = compilePattern(rule.Value)
+		patterns[rule.Value] = compiled
+	}
+
+	return compiled
+}
+
+// Function compiles the pattern, which must match the whole condition value.
+func compilePattern(pattern string) (*regexp.Regexp, error) {
+	re, err := regexp.Compile("^(?:" + pattern + ")$")
+	if err != nil {
+		return nil, errors.BadRequest.Newf("invalid pattern: %s", pattern)
+	}
+	return re, nil
+}
+
+// Function prepares the condition for comparison with rules.
+func compileCondition(name, value string) compiledCondition {
+	number, err := parseFloat(value)
+	return compiledCondition{
+		name:      strings.ToLower(name),
+		value:     value,
+		number:    number,
+		numberErr: err,
+	}
+}
+
+// string to float64
+func parseFloat(s string) (float64, error) {
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, errors.BadRequest.Newf("invalid float value: %s", s)
+	}
+	return f, nil
+}
+
+// Function returns the product rules and all prices as one list.
+func (p *CompiledProduct) allPrices() []compiledPrice {
+	prices := []compiledPrice{{rules: p.rules}}
+	for _, component := range p.components {
+		prices = append(prices, component.prices...)
+	}
+	return prices
+}
@@ -0,0 +1,391 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"go-rti-testing/pkg/errors"
+)
+
+// CSV layout of products: a header row followed by one row per price.
+//
+//	product,productName,component,isMain,cost,priceType,codeName1,operator1,value1,codeName2,operator2,value2
+//	gaming,Игровой,Интернет,true,100,COST,technology,EQ,adsl,internetSpeed,EQ,10
+//	gaming,Игровой,ADSL Модем,false,300,COST,technology,EQ,adsl,,,
+//
+// Columns product, component and cost are required, the rest are optional.
+// Rows of one product share the product ID, the product name may be given on any of them.
+// Components keep the order of their first rows. An empty priceType means COST.
+// Spaces around cell values are trimmed.
+// Each rule of the price takes a numbered codeName/operator/value triple,
+// there can be any number of triples and a fully empty triple is skipped.
+// A triple present in the header must have all three columns.
+// Product rules, mainComponents, groups, bundles, requires and excludes
+// have no columns, so such products cannot be exported.
+const (
+	csvProduct     = "product"
+	csvProductName = "productName"
+	csvComponent   = "component"
+	csvIsMain      = "isMain"
+	csvCost        = "cost"
+	csvPriceType   = "priceType"
+)
+
+var csvRuleColumn = regexp.MustCompile(`^(codeName|operator|value)([1-9][0-9]*)$`)
+
+// CSVError is an error in a cell of the CSV input. Row numbers start with 1 for the header,
+// column is empty when the error concerns the whole row.
+type CSVError struct {
+	Row    int
+	Column string
+	Err    error
+}
+
+func (e *CSVError) Error() string {
+	if e.Column == "" {
+		return fmt.Sprintf("row %d: %v", e.Row, e.Err)
+	}
+	return fmt.Sprintf("row %d, column %s: %v", e.Row, e.Column, e.Err)
+}
+
+// CSVErrors lists all errors found in the CSV input.
+type CSVErrors []*CSVError
+
+func (e CSVErrors) Error() string {
+	messages := make([]string, 0, len(e))
+	for _, err := range e {
+		messages = append(messages, err.Error())
+	}
+	return strings.Join(messages, "; ")
+}
+
+// Positions of the columns in the CSV header and the sorted numbers of its rule triples.
+type csvHeader struct {
+	columns map[string]int
+	rules   []int
+}
+
+// ImportCSV reads products in the CSV layout. All cell errors are collected
+// into CSVErrors available through errors.Cause, products are validated
+// as a whole afterwards.
+func ImportCSV(r io.Reader) ([]Product, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+
+	record, err := reader.Read()
+	if err == io.EOF {
+		return nil, errors.BadRequest.New("empty CSV")
+	}
+	if err != nil {
+		return nil, errors.BadRequest.Wrap(err, "read CSV")
+	}
+	header, errs := parseCSVHeader(record)
+	if len(errs) > 0 {
+		return nil, errors.BadRequest.Wrap(errs, "invalid CSV header")
+	}
+
+	var products []Product
+	index := make(map[string]int)
+	firstRows := make(map[string]int)
+	for row := 2; ; row++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			errs = append(errs, &CSVError{Row: row, Err: err})
+			break
+		}
+		if isEmptyRecord(record) {
+			continue
+		}
+
+		id := header.cell(record, csvProduct)
+		if id == "" {
+			errs = append(errs, &CSVError{Row: row, Column: csvProduct, Err: errors.New("product is required")})
+			errs = append(errs, header.addPrice(new(Product), record, row)...)
+			continue
+		}
+
+		i, ok := index[id]
+		if !ok {
+			i = len(products)
+			index[id] = i
+			firstRows[id] = row
+			products = append(products, Product{ID: id})
+		}
+		errs = append(errs, header.addPrice(&products[i], record, row)...)
+	}
+
+	if len(errs) > 0 {
+		return nil, errors.BadRequest.Wrap(errs, "invalid CSV")
+	}
+
+	for i := range products {
+		if err := ValidateProduct(&products[i]); err != nil {
+			errs = append(errs, &CSVError{Row: firstRows[products[i].ID], Err: errors.Wrapf(err, "product %s", products[i].ID)})
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errors.BadRequest.Wrap(errs, "invalid CSV")
+	}
+
+	return products, nil
+}
+
+func parseCSVHeader(record []string) (*csvHeader, CSVErrors) {
+	header := &csvHeader{columns: make(map[string]int, len(record))}
+	var errs CSVErrors
+	rules := make(map[int]bool)
+
+	for i, name := range record {
+		name = strings.TrimSpace(name)
+		if _, ok := header.columns[name]; ok {
+			errs = append(errs, &CSVError{Row: 1, Column: name, Err: errors.New("duplicate column")})
+			continue
+		}
+		header.columns[name] = i
+
+		switch name {
+		case csvProduct, csvProductName, csvComponent, csvIsMain, csvCost, csvPriceType:
+			continue
+		}
+		m := csvRuleColumn.FindStringSubmatch(name)
+		if m == nil {
+			errs = append(errs, &CSVError{Row: 1, Column: name, Err: errors.New("unknown column")})
+			continue
+		}
+		n, _ := strconv.Atoi(m[2])
+		if !rules[n] {
+			rules[n] = true
+			header.rules = append(header.rules, n)
+		}
+	}
+
+	for _, name := range []string{csvProduct, csvComponent, csvCost} {
+		if _, ok := header.columns[name]; !ok {
+			errs = append(errs, &CSVError{Row: 1, Column: name, Err: errors.New("column is required")})
+		}
+	}
+	// Numbers of the triples need not be consecutive, but every triple must be complete.
+	sort.Ints(header.rules)
+	for _, n := range header.rules {
+		for _, name := range ruleColumns(n) {
+			if _, ok := header.columns[name]; !ok {
+				errs = append(errs, &CSVError{Row: 1, Column: name, Err: errors.New("column is required")})
+			}
+		}
+	}
+
+	return header, errs
+}
+
+// Function adds the price of the row to the product.
+func (h *csvHeader) addPrice(product *Product, record []string, row int) CSVErrors {
+	var errs CSVErrors
+	fail := func(column string, err error) {
+		errs = append(errs, &CSVError{Row: row, Column: column, Err: err})
+	}
+
+	if name := h.cell(record, csvProductName); name != "" {
+		if product.Name != "" && product.Name != name {
+			fail(csvProductName, fmt.Errorf("product name differs from %s", product.Name))
+		}
+		product.Name = name
+	}
+
+	name := h.cell(record, csvComponent)
+	if name == "" {
+		fail(csvComponent, errors.New("component is required"))
+	}
+
+	isMain := false
+	if value := h.cell(record, csvIsMain); value != "" {
+		var err error
+		if isMain, err = strconv.ParseBool(value); err != nil {
+			fail(csvIsMain, fmt.Errorf("invalid boolean: %s", value))
+		}
+	}
+
+	var price Price
+	cost, err := strconv.ParseFloat(h.cell(record, csvCost), 64)
+	switch {
+	case err != nil:
+		fail(csvCost, fmt.Errorf("invalid cost: %s", h.cell(record, csvCost)))
+	case cost < 0:
+		fail(csvCost, errors.New("cost must not be negative"))
+	}
+	price.Cost = cost
+
+	price.PriceType = strings.ToUpper(h.cell(record, csvPriceType))
+	switch price.PriceType {
+	case "":
+		price.PriceType = PriceTypeCost
+	case PriceTypeCost, PriceTypeDiscount:
+	default:
+		fail(csvPriceType, fmt.Errorf("invalid priceType: %s", price.PriceType))
+	}
+
+	for _, n := range h.rules {
+		columns := ruleColumns(n)
+		rule := RuleApplicability{
+			CodeName: h.cell(record, columns[0]),
+			Operator: strings.ToUpper(h.cell(record, columns[1])),
+			Value:    h.cell(record, columns[2]),
+		}
+		if rule == (RuleApplicability{}) {
+			continue
+		}
+
+		switch {
+		case rule.CodeName == "":
+			fail(columns[0], errors.New("codeName is required"))
+		case rule.Operator == "":
+			fail(columns[1], errors.New("operator is required"))
+		default:
+			if err := validateRules([]RuleApplicability{rule}); err != nil {
+				column := columns[2]
+				if !isOperator(rule.Operator) {
+					column = columns[1]
+				}
+				fail(column, errors.Cause(err))
+			}
+		}
+		price.RuleApplicabilities = append(price.RuleApplicabilities, rule)
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+
+	for i := range product.Components {
+		if c := &product.Components[i]; c.Name == name {
+			if c.IsMain != isMain {
+				fail(csvIsMain, fmt.Errorf("isMain differs from the previous rows of %s", name))
+				return errs
+			}
+			c.Prices = append(c.Prices, price)
+			return nil
+		}
+	}
+	product.Components = append(product.Components, Component{Name: name, IsMain: isMain, Prices: []Price{price}})
+	return nil
+}
+
+func (h *csvHeader) cell(record []string, column string) string {
+	i, ok := h.columns[column]
+	if !ok || i >= len(record) {
+		return ""
+	}
+	return strings.TrimSpace(record[i])
+}
+
+// ExportCSV writes the products in the CSV layout.
+// It fails on products using features which have no columns.
+func ExportCSV(w io.Writer, products []Product) error {
+	rules := 0
+	for i := range products {
+		if err := checkCSVExport(&products[i]); err != nil {
+			return err
+		}
+		for _, component := range products[i].Components {
+			for _, price := range component.Prices {
+				if len(price.RuleApplicabilities) > rules {
+					rules = len(price.RuleApplicabilities)
+				}
+			}
+		}
+	}
+
+	writer := csv.NewWriter(w)
+	header := []string{csvProduct, csvProductName, csvComponent, csvIsMain, csvCost, csvPriceType}
+	for n := 1; n <= rules; n++ {
+		header = append(header, ruleColumns(n)...)
+	}
+	if err := writer.Write(header); err != nil {
+		return errors.Internal.Wrap(err, "write CSV")
+	}
+
+	for _, product := range products {
+		for _, component := range product.Components {
+			for _, price := range component.Prices {
+				record := make([]string, 0, len(header))
+				record = append(record, product.ID, product.Name, component.Name,
+					strconv.FormatBool(component.IsMain), formatFloat(price.Cost), price.PriceType)
+				for _, rule := range price.RuleApplicabilities {
+					record = append(record, rule.CodeName, rule.Operator, rule.Value)
+				}
+				for len(record) < len(header) {
+					record = append(record, "")
+				}
+				if err := writer.Write(record); err != nil {
+					return errors.Internal.Wrap(err, "write CSV")
+				}
+			}
+		}
+	}
+
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return errors.Internal.Wrap(err, "write CSV")
+	}
+	return nil
+}
+
+// Function checks that the product fits into the CSV layout.
+func checkCSVExport(product *Product) error {
+	unsupported := func(feature string) error {
+		return errors.BadRequest.Newf("product %s: %s cannot be exported to CSV", product.ID, feature)
+	}
+
+	switch {
+	case product.ID == "":
+		return errors.BadRequest.Newf("product %s: id is required for CSV", product.Name)
+	case len(product.RuleApplicabilities) > 0:
+		return unsupported("product rules")
+	case product.MainComponents != "":
+		return unsupported("mainComponents")
+	case len(product.Groups) > 0:
+		return unsupported("groups")
+	case len(product.Bundles) > 0:
+		return unsupported("bundles")
+	}
+
+	for _, component := range product.Components {
+		switch {
+		case len(component.Prices) == 0:
+			return unsupported(fmt.Sprintf("component %s without prices", component.Name))
+		case len(component.Requires) > 0 || len(component.Excludes) > 0:
+			return unsupported(fmt.Sprintf("requires and excludes of component %s", component.Name))
+		}
+	}
+	return nil
+}
+
+func ruleColumns(n int) []string {
+	suffix := strconv.Itoa(n)
+	return []string{"codeName" + suffix, "operator" + suffix, "value" + suffix}
+}
+
+func isOperator(operator string) bool {
+	switch operator {
+	case OperatorEqual, OperatorGreaterThanOrEqual, OperatorLessThanOrEqual,
+		OperatorMatches, OperatorStartsWith, OperatorEndsWith, OperatorContains:
+		return true
+	}
+	return false
+}
+
+func isEmptyRecord(record []string) bool {
+	for _, cell := range record {
+		if strings.TrimSpace(cell) != "" {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go-rti-testing/pkg/errors"
+)
+
+const productsCSV = `product,productName,component,isMain,cost,priceType,codeName1,operator1,value1,codeName2,operator2,value2
+gaming,Игровой,Интернет,true,100,COST,technology,EQ,adsl,internetSpeed,EQ,10
+gaming,,Интернет,true,10,discount,internetSpeed,gte,10,,,
+gaming,Игровой,ADSL Модем,false,300,,technology,EQ,adsl,,,
+
+home,Домашний,Интернет,true,50,COST,,,,,,
+`
+
+func TestImportCSV(t *testing.T) {
+	products, err := ImportCSV(strings.NewReader(productsCSV))
+	require.NoError(t, err)
+	require.Len(t, products, 2)
+
+	gaming := products[0]
+	assert.Equal(t, "gaming", gaming.ID)
+	assert.Equal(t, "Игровой", gaming.Name)
+	require.Len(t, gaming.Components, 2)
+	assert.True(t, gaming.Components[0].IsMain)
+	require.Len(t, gaming.Components[0].Prices, 2)
+	assert.Equal(t, Price{
+		Cost:      10,
+		PriceType: PriceTypeDiscount,
+		RuleApplicabilities: []RuleApplicability{
+			{CodeName: "internetSpeed", Operator: OperatorGreaterThanOrEqual, Value: "10"},
+		},
+	}, gaming.Components[0].Prices[1])
+	assert.Equal(t, PriceTypeCost, gaming.Components[1].Prices[0].PriceType)
+	assert.Empty(t, products[1].Components[0].Prices[0].RuleApplicabilities)
+
+	offer, err := Calculate(&gaming, []Condition{{RuleName: "technology", Value: "adsl"}, {RuleName: "internetSpeed", Value: "10"}})
+	require.NoError(t, err)
+	assert.Equal(t, 390.0, offer.TotalCost.Cost)
+}
+
+func TestImportCSVErrors(t *testing.T) {
+	tests := []struct {
+		input  string
+		errors []string
+	}{
+		{
+			"product,component,price\n",
+			[]string{"row 1, column price: unknown column", "row 1, column cost: column is required"},
+		},
+		{
+			"product,component,cost,codeName1,operator1\n",
+			[]string{"row 1, column value1: column is required"},
+		},
+		{
+			"product,component,cost,codeName3000000\n",
+			[]string{"row 1, column operator3000000: column is required", "row 1, column value3000000: column is required"},
+		},
+		{
+			"product,component,isMain,cost,priceType,codeName1,operator1,value1\n" +
+				",Интернет,yes,-1,FREE,speed,,\n" +
+				"gaming,Интернет,true,10,COST,speed,GTE,fast\n" +
+				"gaming,Интернет,true,10,COST,speed,LIKE,10\n",
+			[]string{
+				"row 2, column product: product is required",
+				"row 2, column isMain: invalid boolean: yes",
+				"row 2, column cost: cost must not be negative",
+				"row 2, column priceType: invalid priceType: FREE",
+				"row 2, column operator1: operator is required",
+				"row 3, column value1: invalid float value: fast",
+				"row 4, column operator1: invalid operator: LIKE",
+			},
+		},
+		{
+			"product,component,isMain,cost\ngaming,Интернет,true,10\ngaming,Интернет,false,20\n",
+			[]string{"row 3, column isMain: isMain differs from the previous rows of Интернет"},
+		},
+		{
+			"product,component,cost\ngaming,Интернет,10\n",
+			[]string{"row 2: product gaming: product name is required"},
+		},
+	}
+
+	for _, tt := range tests {
+		_, err := ImportCSV(strings.NewReader(tt.input))
+		require.Error(t, err, tt.input)
+		assert.Equal(t, errors.BadRequest, errors.GetType(err))
+
+		csvErrs, ok := errors.Cause(err).(CSVErrors)
+		require.True(t, ok, err.Error())
+		messages := make([]string, 0, len(csvErrs))
+		for _, e := range csvErrs {
+			messages = append(messages, e.Error())
+		}
+		assert.Equal(t, tt.errors, messages)
+	}
+}
+
+func TestExportCSV(t *testing.T) {
+	products, err := ImportCSV(strings.NewReader(productsCSV))
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	require.NoError(t, ExportCSV(&buf, products))
+	assert.True(t, strings.HasPrefix(buf.String(), "product,productName,component,isMain,cost,priceType,codeName1,operator1,value1,codeName2,operator2,value2\n"))
+
+	imported, err := ImportCSV(&buf)
+	require.NoError(t, err)
+	assert.Equal(t, products, imported)
+
+	withGroups := products[0]
+	withGroups.Groups = []ComponentGroup{{Name: "Модемы", Components: []string{"ADSL Модем"}}}
+	err = ExportCSV(&buf, []Product{withGroups})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "groups cannot be exported to CSV")
+}
+
+func TestLoadCatalogCSV(t *testing.T) {
+	dir := writeCatalog(t, nil)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "tariffs.csv"), []byte(productsCSV), 0644))
+
+	catalog, err := LoadCatalog(dir)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"gaming", "home"}, catalog.IDs())
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"go-rti-testing/pkg/errors"
+)
+
+// Function drops components whose requirements are not in the offer and
+// resolves mutual exclusions. Of two excluding components the mandatory one
+// is kept, then the explicitly included one, otherwise the one declaring
+// the exclusion is dropped. If a mandatory or an explicitly included component
+// has to be dropped, then returns the reason or the selection error instead.
+func resolveDependencies(components []offeredComponent, selected *selection) ([]offeredComponent, []ExcludedComponent, string, error) {
+	var excluded []ExcludedComponent
+
+	drop := func(i int, reason string) (string, error) {
+		c := components[i].component
+		if c.mandatory {
+			return fmt.Sprintf("main component %s %s", c.name, reason), nil
+		}
+		if selected.included(c) {
+			return "", selectionError{errors.BadRequest.Newf("component %s %s", c.name, reason)}
+		}
+
+		excluded = append(excluded, ExcludedComponent{Name: c.name, Reason: reason})
+		components = append(components[:i:i], components[i+1:]...)
+		return "", nil
+	}
+
+	requirements := func() (string, error) {
+		for i := 0; i < len(components); {
+			if missing := missingRequirements(components, components[i].component); len(missing) > 0 {
+				if reason, err := drop(i, "requires "+strings.Join(missing, ", ")); err != nil || reason != "" {
+					return reason, err
+				}
+				i = 0
+				continue
+			}
+			i++
+		}
+		return "", nil
+	}
+
+	if reason, err := requirements(); err != nil || reason != "" {
+		return nil, nil, reason, err
+	}
+
+	for i := 0; i < len(components); {
+		j, declared := exclusion(components, i)
+		if j < 0 {
+			i++
+			continue
+		}
+
+		a, b := components[i].component, components[j].component
+		if a.mandatory && b.mandatory {
+			return nil, nil, fmt.Sprintf("main components %s and %s exclude each other", a.name, b.name), nil
+		}
+		if selected.included(a) && selected.included(b) {
+			return nil, nil, "", selectionError{errors.BadRequest.Newf("components %s and %s exclude each other", a.name, b.name)}
+		}
+
+		keep := j
+		switch {
+		case a.mandatory != b.mandatory:
+			if a.mandatory {
+				keep = i
+			}
+		case selected.included(a) != selected.included(b):
+			if selected.included(a) {
+				keep = i
+			}
+		case !declared:
+			keep = i
+		}
+
+		dropped := i + j - keep
+		if reason, err := drop(dropped, "excluded by "+components[keep].component.name); err != nil || reason != "" {
+			return nil, nil, reason, err
+		}
+		i = 0
+	}
+
+	if reason, err := requirements(); err != nil || reason != "" {
+		return nil, nil, reason, err
+	}
+
+	return components, excluded, "", nil
+}
+
+// Function returns the requirements of the component missing in the offer.
+func missingRequirements(components []offeredComponent, c *compiledComponent) []string {
+	var missing []string
+	for _, name := range c.requires {
+		if indexOfComponent(components, name) < 0 {
+			missing = append(missing, name)
+		}
+	}
+	return missing
+}
+
+// Function returns the index of a component conflicting with the i-th one
+// and whether the i-th component declares the exclusion.
+func exclusion(components []offeredComponent, i int) (int, bool) {
+	c := components[i].component
+	for _, name := range c.excludes {
+		if j := indexOfComponent(components, name); j >= 0 && j != i {
+			return j, true
+		}
+	}
+	for j := range components {
+		if j != i && containsString(components[j].component.excludes, c.name) {
+			return j, false
+		}
+	}
+	return -1, false
+}
+
+func indexOfComponent(components []offeredComponent, name string) int {
+	for i := range components {
+		if components[i].component.name == name {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,347 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+
+	"go-rti-testing/pkg/errors"
+)
+
+// Maximum number of condition sets calculated for one product by a diff.
+const maxDiffCombinations = 10000
+
+// CatalogDiff lists differences between two product sets.
+// Products are matched by ID or by name if they have no ID.
+type CatalogDiff struct {
+	AddedProducts   []string      `json:"addedProducts,omitempty"`
+	RemovedProducts []string      `json:"removedProducts,omitempty"`
+	Products        []ProductDiff `json:"products,omitempty"`
+}
+
+// ProductDiff lists differences of a product present in both sets.
+// Changes describes differences without a list of their own,
+// such as groups, bundles or component relations.
+type ProductDiff struct {
+	Product           string        `json:"product"`
+	AddedComponents   []string      `json:"addedComponents,omitempty"`
+	RemovedComponents []string      `json:"removedComponents,omitempty"`
+	CostChanges       []CostChange  `json:"costChanges,omitempty"`
+	RuleChanges       []RuleChange  `json:"ruleChanges,omitempty"`
+	Changes           []string      `json:"changes,omitempty"`
+	OfferChanges      []OfferChange `json:"offerChanges,omitempty"`
+}
+
+// CostChange is a changed cost of a price of the component.
+// A missing cost means the price was added or removed.
+type CostChange struct {
+	Component string   `json:"component"`
+	Price     int      `json:"price"`
+	Old       *float64 `json:"old,omitempty"`
+	New       *float64 `json:"new,omitempty"`
+}
+
+// RuleChange is a changed rule list of a price or of the product if there is no component.
+type RuleChange struct {
+	Component string              `json:"component,omitempty"`
+	Price     *int                `json:"price,omitempty"`
+	Old       []RuleApplicability `json:"old"`
+	New       []RuleApplicability `json:"new"`
+}
+
+// OfferChange is a condition set whose total cost differs.
+// A missing total cost means there is no offer.
+type OfferChange struct {
+	Conditions []Condition `json:"conditions"`
+	Old        *float64    `json:"old,omitempty"`
+	New        *float64    `json:"new,omitempty"`
+}
+
+// Empty reports whether the sets are equal.
+func (d *CatalogDiff) Empty() bool {
+	return len(d.AddedProducts)+len(d.RemovedProducts)+len(d.Products) == 0
+}
+
+// DiffProducts compares two product sets. Offer changes are found by calculating
+// both versions of a product for condition sets covering every rule of both versions.
+func DiffProducts(old, new []Product) (*CatalogDiff, error) {
+	oldIndex, newIndex := indexProducts(old), indexProducts(new)
+	diff := &CatalogDiff{}
+
+	for _, key := range sortedProductKeys(oldIndex) {
+		if _, ok := newIndex[key]; !ok {
+			diff.RemovedProducts = append(diff.RemovedProducts, key)
+		}
+	}
+	for _, key := range sortedProductKeys(newIndex) {
+		oldProduct, ok := oldIndex[key]
+		if !ok {
+			diff.AddedProducts = append(diff.AddedProducts, key)
+			continue
+		}
+
+		productDiff, err := diffProduct(key, oldProduct, newIndex[key])
+		if err != nil {
+			return nil, errors.Wrapf(err, "product %s", key)
+		}
+		if productDiff != nil {
+			diff.Products = append(diff.Products, *productDiff)
+		}
+	}
+
+	return diff, nil
+}
+
+// Function compares two versions of the product. If they are equal, then returns nil.
+func diffProduct(key string, old, new *Product) (*ProductDiff, error) {
+	if reflect.DeepEqual(old, new) {
+		return nil, nil
+	}
+
+	diff := &ProductDiff{Product: key}
+	if old.Name != new.Name {
+		diff.Changes = append(diff.Changes, fmt.Sprintf("name changed from %s to %s", old.Name, new.Name))
+	}
+	if !strings.EqualFold(old.MainComponents, new.MainComponents) {
+		diff.Changes = append(diff.Changes, "mainComponents changed")
+	}
+	if !reflect.DeepEqual(old.Groups, new.Groups) {
+		diff.Changes = append(diff.Changes, "groups changed")
+	}
+	if !reflect.DeepEqual(old.Bundles, new.Bundles) {
+		diff.Changes = append(diff.Changes, "bundles changed")
+	}
+	if !reflect.DeepEqual(old.RuleApplicabilities, new.RuleApplicabilities) {
+		diff.RuleChanges = append(diff.RuleChanges, RuleChange{Old: old.RuleApplicabilities, New: new.RuleApplicabilities})
+	}
+
+	for _, c := range old.Components {
+		if findComponent(new, c.Name) == nil {
+			diff.RemovedComponents = append(diff.RemovedComponents, c.Name)
+		}
+	}
+	for i := range new.Components {
+		n := &new.Components[i]
+		o := findComponent(old, n.Name)
+		if o == nil {
+			diff.AddedComponents = append(diff.AddedComponents, n.Name)
+			continue
+		}
+		diff.diffComponent(o, n)
+	}
+
+	changes, err := diffOffers(Compile(old), Compile(new))
+	if err != nil {
+		return nil, err
+	}
+	diff.OfferChanges = changes
+
+	return diff, nil
+}
+
+// Function compares prices and relations of the component.
+func (d *ProductDiff) diffComponent(old, new *Component) {
+	if old.IsMain != new.IsMain {
+		d.Changes = append(d.Changes, fmt.Sprintf("isMain of %s changed", new.Name))
+	}
+	if !reflect.DeepEqual(old.Requires, new.Requires) || !reflect.DeepEqual(old.Excludes, new.Excludes) {
+		d.Changes = append(d.Changes, fmt.Sprintf("requires or excludes of %s changed", new.Name))
+	}
+
+	for i := 0; i < len(old.Prices) || i < len(new.Prices); i++ {
+		var o, n *Price
+		if i < len(old.Prices) {
+			o = &old.Prices[i]
+		}
+		if i < len(new.Prices) {
+			n = &new.Prices[i]
+		}
+
+		if o == nil || n == nil || o.Cost != n.Cost {
+			change := CostChange{Component: new.Name, Price: i}
+			if o != nil {
+				change.Old = &o.Cost
+			}
+			if n != nil {
+				change.New = &n.Cost
+			}
+			d.CostChanges = append(d.CostChanges, change)
+		}
+		if o == nil || n == nil {
+			continue
+		}
+
+		if !strings.EqualFold(o.PriceType, n.PriceType) {
+			d.Changes = append(d.Changes, fmt.Sprintf("priceType of %s price %d changed from %s to %s", new.Name, i, o.PriceType, n.PriceType))
+		}
+		if !reflect.DeepEqual(o.RuleApplicabilities, n.RuleApplicabilities) {
+			price := i
+			d.RuleChanges = append(d.RuleChanges, RuleChange{
+				Component: new.Name,
+				Price:     &price,
+				Old:       o.RuleApplicabilities,
+				New:       n.RuleApplicabilities,
+			})
+		}
+	}
+}
+
+// Function calculates both versions for the representative values of every codeName
+// used by them, including an absent condition, and returns the sets with different
+// total costs. A set is left out when a smaller reported set already shows the same change.
+func diffOffers(old, new *CompiledProduct) ([]OfferChange, error) {
+	prices := append(old.allPrices(), new.allPrices()...)
+	options := newConditionOptions(representativeValues(prices), ruleNames(prices), func(string) bool { return true })
+	if countCombinations(options, maxDiffCombinations) > maxDiffCombinations {
+		return nil, errors.BadRequest.Newf("too many condition combinations, maximum is %d", maxDiffCombinations)
+	}
+
+	var changes []OfferChange
+	var found [][]compiledCondition
+	var err error
+	forEachCombination(options, func(conditions []compiledCondition) {
+		if err != nil {
+			return
+		}
+
+		var oldCost, newCost *float64
+		if oldCost, err = offerCost(old, conditions); err != nil {
+			return
+		}
+		if newCost, err = offerCost(new, conditions); err != nil {
+			return
+		}
+		if equalCosts(oldCost, newCost) {
+			return
+		}
+
+		for i, other := range found {
+			if containsConditions(conditions, other) && equalCosts(changes[i].Old, oldCost) && equalCosts(changes[i].New, newCost) {
+				return
+			}
+		}
+		found = append(found, append([]compiledCondition(nil), conditions...))
+		changes = append(changes, OfferChange{Conditions: toConditions(conditions, options), Old: oldCost, New: newCost})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return changes, nil
+}
+
+func offerCost(p *CompiledProduct, conditions []compiledCondition) (*float64, error) {
+	offer, _, err := p.calculateOffer(conditions, nil)
+	if err != nil || offer == nil {
+		return nil, err
+	}
+	return &offer.TotalCost.Cost, nil
+}
+
+func equalCosts(a, b *float64) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+func indexProducts(products []Product) map[string]*Product {
+	index := make(map[string]*Product, len(products))
+	for i := range products {
+		key := products[i].ID
+		if key == "" {
+			key = products[i].Name
+		}
+		index[key] = &products[i]
+	}
+	return index
+}
+
+func sortedProductKeys(index map[string]*Product) []string {
+	keys := make([]string, 0, len(index))
+	for key := range index {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func findComponent(product *Product, name string) *Component {
+	for i := range product.Components {
+		if product.Components[i].Name == name {
+			return &product.Components[i]
+		}
+	}
+	return nil
+}
+
+// WriteText writes the differences in a human readable form.
+func (d *CatalogDiff) WriteText(w io.Writer) error {
+	var b strings.Builder
+	for _, key := range d.RemovedProducts {
+		fmt.Fprintf(&b, "- product %s\n", key)
+	}
+	for _, key := range d.AddedProducts {
+		fmt.Fprintf(&b, "+ product %s\n", key)
+	}
+
+	for _, p := range d.Products {
+		fmt.Fprintf(&b, "~ product %s\n", p.Product)
+		for _, name := range p.RemovedComponents {
+			fmt.Fprintf(&b, "  - component %s\n", name)
+		}
+		for _, name := range p.AddedComponents {
+			fmt.Fprintf(&b, "  + component %s\n", name)
+		}
+		for _, c := range p.CostChanges {
+			fmt.Fprintf(&b, "  ~ cost of %s price %d: %s -> %s\n", c.Component, c.Price, formatCost(c.Old), formatCost(c.New))
+		}
+		for _, r := range p.RuleChanges {
+			where := "product rules"
+			if r.Price != nil {
+				where = fmt.Sprintf("rules of %s price %d", r.Component, *r.Price)
+			}
+			fmt.Fprintf(&b, "  ~ %s: %s -> %s\n", where, formatRules(r.Old), formatRules(r.New))
+		}
+		for _, change := range p.Changes {
+			fmt.Fprintf(&b, "  ~ %s\n", change)
+		}
+		for _, o := range p.OfferChanges {
+			fmt.Fprintf(&b, "  ~ total cost with %s: %s -> %s\n", formatConditions(o.Conditions), formatCost(o.Old), formatCost(o.New))
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func formatCost(cost *float64) string {
+	if cost == nil {
+		return "none"
+	}
+	return formatFloat(*cost)
+}
+
+func formatRules(rules []RuleApplicability) string {
+	if len(rules) == 0 {
+		return "none"
+	}
+	parts := make([]string, 0, len(rules))
+	for _, r := range rules {
+		parts = append(parts, fmt.Sprintf("%s %s %s", r.CodeName, r.Operator, r.Value))
+	}
+	return strings.Join(parts, ", ")
+}
+
+func formatConditions(conditions []Condition) string {
+	if len(conditions) == 0 {
+		return "no conditions"
+	}
+	parts := make([]string, 0, len(conditions))
+	for _, c := range conditions {
+		parts = append(parts, fmt.Sprintf("%s=%s", c.RuleName, c.Value))
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func diffTestProduct(internetCost float64, extra Component) Product {
+	technology := func(value string) []RuleApplicability {
+		return []RuleApplicability{{CodeName: "technology", Operator: OperatorEqual, Value: value}}
+	}
+	return Product{
+		ID:   "home",
+		Name: "Домашний",
+		Components: []Component{
+			{
+				Name:   "Интернет",
+				IsMain: true,
+				Prices: []Price{
+					{Cost: internetCost, PriceType: PriceTypeCost, RuleApplicabilities: technology("adsl")},
+					{Cost: 500, PriceType: PriceTypeCost, RuleApplicabilities: technology("xpon")},
+				},
+			},
+			extra,
+		},
+	}
+}
+
+func TestDiffProducts(t *testing.T) {
+	old := []Product{
+		diffTestProduct(100, Component{Name: "Модем", Prices: []Price{{Cost: 50, PriceType: PriceTypeCost,
+			RuleApplicabilities: []RuleApplicability{{CodeName: "technology", Operator: OperatorEqual, Value: "adsl"}}}}}),
+		{ID: "office", Name: "Офисный"},
+	}
+	new := []Product{
+		diffTestProduct(120, Component{Name: "Роутер", Prices: []Price{{Cost: 40, PriceType: PriceTypeCost}}}),
+		{ID: "gaming", Name: "Игровой"},
+	}
+
+	diff, err := DiffProducts(old, new)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"gaming"}, diff.AddedProducts)
+	assert.Equal(t, []string{"office"}, diff.RemovedProducts)
+	require.Len(t, diff.Products, 1)
+
+	home := diff.Products[0]
+	assert.Equal(t, "home", home.Product)
+	assert.Equal(t, []string{"Роутер"}, home.AddedComponents)
+	assert.Equal(t, []string{"Модем"}, home.RemovedComponents)
+	require.Len(t, home.CostChanges, 1)
+	assert.Equal(t, 0, home.CostChanges[0].Price)
+	assert.Equal(t, 100.0, *home.CostChanges[0].Old)
+	assert.Equal(t, 120.0, *home.CostChanges[0].New)
+
+	require.Len(t, home.OfferChanges, 2)
+	assert.Equal(t, []Condition{{RuleName: "technology", Value: "adsl"}}, home.OfferChanges[0].Conditions)
+	assert.Equal(t, 150.0, *home.OfferChanges[0].Old)
+	assert.Equal(t, 160.0, *home.OfferChanges[0].New)
+	assert.Equal(t, []Condition{{RuleName: "technology", Value: "xpon"}}, home.OfferChanges[1].Conditions)
+	assert.Equal(t, 500.0, *home.OfferChanges[1].Old)
+	assert.Equal(t, 540.0, *home.OfferChanges[1].New)
+
+	same, err := DiffProducts(old, old)
+	require.NoError(t, err)
+	assert.True(t, same.Empty())
+}
+
+func TestDiffOfferAppearance(t *testing.T) {
+	old := diffTestProduct(100, Component{Name: "Модем", Prices: []Price{{Cost: 50, PriceType: PriceTypeCost}}})
+	new := old
+	new.Components = []Component{old.Components[0], old.Components[1]}
+	new.Components[0].Prices = []Price{old.Components[0].Prices[0], {Cost: 500, PriceType: PriceTypeCost,
+		RuleApplicabilities: []RuleApplicability{{CodeName: "technology", Operator: OperatorEqual, Value: "fttb"}}}}
+
+	diff, err := DiffProducts([]Product{old}, []Product{new})
+	require.NoError(t, err)
+	require.Len(t, diff.Products, 1)
+	assert.Len(t, diff.Products[0].RuleChanges, 1)
+
+	changes := diff.Products[0].OfferChanges
+	require.Len(t, changes, 2)
+	assert.Equal(t, "xpon", changes[0].Conditions[0].Value)
+	assert.Equal(t, 550.0, *changes[0].Old)
+	assert.Nil(t, changes[0].New)
+	assert.Equal(t, "fttb", changes[1].Conditions[0].Value)
+	assert.Nil(t, changes[1].Old)
+	assert.Equal(t, 550.0, *changes[1].New)
+}
+
+func TestRunDiff(t *testing.T) {
+	oldDir := writeCatalog(t, map[string]interface{}{
+		"home.json": diffTestProduct(100, Component{Name: "Модем", Prices: []Price{{Cost: 50, PriceType: PriceTypeCost}}}),
+	})
+	newFile := filepath.Join(writeCatalog(t, nil), "home.yaml")
+	product := diffTestProduct(120, Component{Name: "Модем", Prices: []Price{{Cost: 50, PriceType: PriceTypeCost}}})
+	data, err := MarshalProductYAML(&product, nil)
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(newFile, data, 0644))
+
+	var stdout, stderr bytes.Buffer
+	code := runDiff([]string{oldDir, newFile}, &stdout, &stderr)
+	assert.Equal(t, 1, code, stderr.String())
+	assert.Equal(t, strings.Join([]string{
+		"~ product home",
+		"  ~ cost of Интернет price 0: 100 -> 120",
+		"  ~ total cost with technology=adsl: 150 -> 170",
+		"",
+	}, "\n"), stdout.String())
+
+	stdout.Reset()
+	assert.Equal(t, 0, runDiff([]string{"-json", oldDir, oldDir}, &stdout, &stderr))
+	assert.Equal(t, "{}\n", stdout.String())
+
+	assert.Equal(t, 2, runDiff([]string{oldDir}, &stdout, &stderr))
+	assert.Equal(t, 2, runDiff([]string{oldDir, filepath.Join(oldDir, "missing.json")}, &stdout, &stderr))
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Set of condition values for one codeName. A nil value stands for
+// the condition being absent, in which case every rule of the codeName is met.
+type conditionOptions struct {
+	name   string
+	values []*compiledCondition
+}
+
+// Function derives representative values of every codeName used by the prices.
+// Each value behaves like a whole class of condition values, so checking
+// the representatives covers every possible condition value. Pattern rules
+// are covered by sample values only.
+func representativeValues(prices []compiledPrice) map[string][]string {
+	rules := make(map[string][]compiledRule)
+	for _, price := range prices {
+		for name, r := range price.rules {
+			rules[name] = append(rules[name], r...)
+		}
+	}
+
+	values := make(map[string][]string, len(rules))
+	for name, r := range rules {
+		var numbers []float64
+		var candidates []string
+		numeric := false
+
+		for _, rule := range r {
+			switch rule.operator {
+			case OperatorGreaterThanOrEqual, OperatorLessThanOrEqual:
+				numeric = true
+				if rule.err == nil {
+					numbers = append(numbers, rule.number)
+				}
+			case OperatorEqual:
+				candidates = append(candidates, rule.value)
+				if number, err := strconv.ParseFloat(rule.value, 64); err == nil {
+					numbers = append(numbers, number)
+				}
+			case OperatorStartsWith, OperatorEndsWith, OperatorContains:
+				candidates = append(candidates, rule.value)
+			case OperatorMatches:
+				candidates = append(candidates, patternSample(rule.value))
+			}
+		}
+
+		if numeric {
+			candidates = append(candidates, numericRepresentatives(numbers, nil, nil)...)
+		} else {
+			// Value which matches none of the EQ rules.
+			candidates = append(candidates, "")
+		}
+
+		values[name] = uniqueStrings(candidates)
+	}
+
+	return values
+}
+
+// Function returns numbers splitting [from, to] into classes by the thresholds:
+// the bounds, the thresholds themselves and a point between each pair of them.
+// A missing bound is replaced with a point beyond the outermost threshold.
+func numericRepresentatives(thresholds []float64, from, to *float64) []string {
+	sort.Float64s(thresholds)
+
+	var points []float64
+	if from != nil {
+		points = append(points, *from)
+	}
+	for _, threshold := range thresholds {
+		if (from == nil || threshold > *from) && (to == nil || threshold < *to) {
+			points = append(points, threshold)
+		}
+	}
+	if to != nil {
+		points = append(points, *to)
+	}
+
+	var values []string
+	if from == nil && len(points) > 0 {
+		values = append(values, formatFloat(points[0]-1))
+	}
+	for i, point := range points {
+		if i > 0 && point > points[i-1] {
+			values = append(values, formatFloat((points[i-1]+point)/2))
+		}
+		values = append(values, formatFloat(point))
+	}
+	if to == nil && len(points) > 0 {
+		values = append(values, formatFloat(points[len(points)-1]+1))
+	}
+
+	return uniqueStrings(values)
+}
+
+// Function returns the rule names used by the prices keyed by lowercase codeName.
+func ruleNames(prices []compiledPrice) map[string]string {
+	names := make(map[string]string)
+	for _, price := range prices {
+		for name, rules := range price.rules {
+			if _, ok := names[name]; !ok {
+				names[name] = rules[0].codeName
+			}
+		}
+	}
+	return names
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+func uniqueStrings(values []string) []string {
+	var unique []string
+	for _, value := range values {
+		if !containsString(unique, value) {
+			unique = append(unique, value)
+		}
+	}
+	return unique
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// Function builds condition options from values keyed by lowercase codeName.
+// Spelling holds the rule names to report, absent adds the missing condition option.
+func newConditionOptions(values map[string][]string, spelling map[string]string, absent func(name string) bool) []conditionOptions {
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	options := make([]conditionOptions, 0, len(names))
+	for _, name := range names {
+		o := conditionOptions{name: spelling[name]}
+		if o.name == "" {
+			o.name = name
+		}
+		if absent != nil && absent(name) {
+			o.values = append(o.values, nil)
+		}
+		for _, value := range values[name] {
+			condition := compileCondition(name, value)
+			o.values = append(o.values, &condition)
+		}
+		options = append(options, o)
+	}
+
+	return options
+}
+
+// Function returns the number of condition sets produced by the options.
+// The count stops growing once it exceeds the limit.
+func countCombinations(options []conditionOptions, limit int) int {
+	count := 1
+	for _, o := range options {
+		count *= len(o.values)
+		if count > limit {
+			return count
+		}
+	}
+	return count
+}
+
+// Function calls fn for every combination of the options.
+// Absent conditions are left out of the set passed to fn.
+func forEachCombination(options []conditionOptions, fn func(conditions []compiledCondition)) {
+	for _, o := range options {
+		if len(o.values) == 0 {
+			return
+		}
+	}
+
+	indexes := make([]int, len(options))
+	conditions := make([]compiledCondition, 0, len(options))
+	for {
+		conditions = conditions[:0]
+		for i, o := range options {
+			if value := o.values[indexes[i]]; value != nil {
+				conditions = append(conditions, *value)
+			}
+		}
+		fn(conditions)
+
+		i := len(indexes) - 1
+		for ; i >= 0; i-- {
+			indexes[i]++
+			if indexes[i] < len(options[i].values) {
+				break
+			}
+			indexes[i] = 0
+		}
+		if i < 0 {
+			return
+		}
+	}
+}
+
+// Function converts compiled conditions back to request conditions
+// using the rule names of the options.
+func toConditions(compiled []compiledCondition, options []conditionOptions) []Condition {
+	spelling := make(map[string]string, len(options))
+	for _, o := range options {
+		spelling[strings.ToLower(o.name)] = o.name
+	}
+
+	conditions := make([]Condition, 0, len(compiled))
+	for _, c := range compiled {
+		conditions = append(conditions, Condition{RuleName: spelling[c.name], Value: c.value})
+	}
+	return conditions
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"go-rti-testing/pkg/errors"
+)
+
+// Maximum number of condition sets checked by an enumeration.
+const maxEnumerationCombinations = 10000
+
+// ConditionDomain lists the values a condition can take. A numeric range
+// is covered by the values splitting it by the thresholds of the product rules.
+type ConditionDomain struct {
+	RuleName string   `json:"ruleName"`
+	Values   []string `json:"values,omitempty"`
+	From     *float64 `json:"from,omitempty"`
+	To       *float64 `json:"to,omitempty"`
+}
+
+// Enumeration lists every distinct offer reachable within the domains
+// and the condition sets without an offer.
+type Enumeration struct {
+	Offers      []ReachableOffer `json:"offers"`
+	Unavailable [][]Condition    `json:"unavailable"`
+}
+
+// ReachableOffer is an offer with the condition sets producing it.
+type ReachableOffer struct {
+	Offer         *Offer        `json:"offer"`
+	ConditionSets [][]Condition `json:"conditionSets"`
+}
+
+// Enumerate calculates the product for every combination of the domain values.
+func Enumerate(product *Product, domains []ConditionDomain) (*Enumeration, error) {
+	compiled := Compile(product)
+	if compiled == nil {
+		return nil, errors.BadRequest.New("product is required")
+	}
+
+	values, spelling, err := compiled.domainValues(domains)
+	if err != nil {
+		return nil, err
+	}
+
+	options := newConditionOptions(values, spelling, nil)
+	if countCombinations(options, maxEnumerationCombinations) > maxEnumerationCombinations {
+		return nil, errors.BadRequest.Newf("too many condition combinations, maximum is %d", maxEnumerationCombinations)
+	}
+
+	enumeration := &Enumeration{Offers: []ReachableOffer{}, Unavailable: [][]Condition{}}
+	index := make(map[string]int)
+	forEachCombination(options, func(set []compiledCondition) {
+		if err != nil {
+			return
+		}
+
+		var offer *Offer
+		offer, _, err = compiled.calculateOffer(set, nil)
+		if err != nil {
+			return
+		}
+
+		conditions := toConditions(set, options)
+		if offer == nil {
+			enumeration.Unavailable = append(enumeration.Unavailable, conditions)
+			return
+		}
+
+		key, _ := json.Marshal(offer)
+		i, ok := index[string(key)]
+		if !ok {
+			i = len(enumeration.Offers)
+			index[string(key)] = i
+			enumeration.Offers = append(enumeration.Offers, ReachableOffer{Offer: offer})
+		}
+		enumeration.Offers[i].ConditionSets = append(enumeration.Offers[i].ConditionSets, conditions)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return enumeration, nil
+}
+
+// Function converts the domains to values keyed by lowercase rule name.
+func (p *CompiledProduct) domainValues(domains []ConditionDomain) (map[string][]string, map[string]string, error) {
+	values := make(map[string][]string, len(domains))
+	spelling := make(map[string]string, len(domains))
+
+	for _, domain := range domains {
+		name := strings.ToLower(domain.RuleName)
+		if domain.From != nil && domain.To != nil && *domain.From > *domain.To {
+			return nil, nil, errors.BadRequest.Newf("invalid range of %s: from is greater than to", domain.RuleName)
+		}
+
+		domainValues := domain.Values
+		if domain.From != nil || domain.To != nil {
+			domainValues = append(domainValues, numericRepresentatives(p.thresholds(name), domain.From, domain.To)...)
+		}
+		if len(domainValues) == 0 {
+			return nil, nil, errors.BadRequest.Newf("domain of %s has no values", domain.RuleName)
+		}
+
+		if _, ok := spelling[name]; !ok {
+			spelling[name] = domain.RuleName
+		}
+		values[name] = uniqueStrings(append(values[name], domainValues...))
+	}
+
+	return values, spelling, nil
+}
+
+// Function returns the numbers compared with the codeName in the product
+// and price rules.
+func (p *CompiledProduct) thresholds(name string) []float64 {
+	var numbers []float64
+	for _, price := range p.allPrices() {
+		for _, rule := range price.rules[name] {
+			switch rule.operator {
+			case OperatorGreaterThanOrEqual, OperatorLessThanOrEqual:
+				if rule.err == nil {
+					numbers = append(numbers, rule.number)
+				}
+			case OperatorEqual:
+				if number, err := strconv.ParseFloat(rule.value, 64); err == nil {
+					numbers = append(numbers, number)
+				}
+			}
+		}
+	}
+	return numbers
+}
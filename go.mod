@@ -6,4 +6,5 @@ require (
 	github.com/felixge/httpsnoop v1.0.1
 	github.com/pkg/errors v0.9.1
 	github.com/stretchr/testify v1.6.1
+	gopkg.in/yaml.v3 v3.0.1
 )
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+
+	"go-rti-testing/pkg/errors"
+)
+
+type compiledGroup struct {
+	name       string
+	components []string
+	min        int
+	max        int
+}
+
+// Function keeps the cheapest valid members of every group up to its maximum.
+// If the user chose members of a group, then only the chosen ones are offered,
+// so the choice is kept as is.
+func (p *CompiledProduct) applyGroups(components []offeredComponent) ([]offeredComponent, []ExcludedComponent) {
+	var excluded []ExcludedComponent
+
+	for _, group := range p.groups {
+		if group.max == 0 {
+			continue
+		}
+
+		var members []int
+		for i := range components {
+			if containsString(group.components, components[i].component.name) && !components[i].component.mandatory {
+				members = append(members, i)
+			}
+		}
+		sort.SliceStable(members, func(i, j int) bool {
+			return components[members[i]].cost < components[members[j]].cost
+		})
+
+		limit := group.max - group.mandatoryMembers(components)
+		if limit < 0 {
+			limit = 0
+		}
+		if len(members) <= limit {
+			continue
+		}
+
+		dropped := make(map[int]bool)
+		for _, i := range members[limit:] {
+			dropped[i] = true
+			excluded = append(excluded, ExcludedComponent{
+				Name:   components[i].component.name,
+				Reason: "not chosen in group " + group.name,
+			})
+		}
+
+		kept := make([]offeredComponent, 0, len(components)-len(dropped))
+		for i := range components {
+			if !dropped[i] {
+				kept = append(kept, components[i])
+			}
+		}
+		components = kept
+	}
+
+	return components, excluded
+}
+
+// Function returns the reason why the offer does not meet a group minimum.
+func (p *CompiledProduct) checkGroups(components []offeredComponent) string {
+	for _, group := range p.groups {
+		count := 0
+		for i := range components {
+			if containsString(group.components, components[i].component.name) {
+				count++
+			}
+		}
+		if count < group.min {
+			return fmt.Sprintf("group %s has fewer than %d available components", group.name, group.min)
+		}
+	}
+	return ""
+}
+
+// Function returns the number of mandatory components of the group in the offer.
+func (g *compiledGroup) mandatoryMembers(components []offeredComponent) int {
+	count := 0
+	for i := range components {
+		if components[i].component.mandatory && containsString(g.components, components[i].component.name) {
+			count++
+		}
+	}
+	return count
+}
+
+// Function checks that the chosen members of every group fit its maximum.
+func (p *CompiledProduct) checkGroupChoice(include map[string]bool) error {
+	for _, group := range p.groups {
+		count := 0
+		for _, name := range group.components {
+			if include[name] {
+				count++
+			}
+		}
+		if group.max > 0 && count > group.max {
+			return errors.BadRequest.Newf("group %s allows at most %d components", group.name, group.max)
+		}
+	}
+	return nil
+}
+
+// Function returns the groups the user chose no members of.
+func (p *CompiledProduct) unchosenGroups(include map[string]bool) []*compiledGroup {
+	var groups []*compiledGroup
+	for i := range p.groups {
+		chosen := false
+		for _, name := range p.groups[i].components {
+			if include[name] {
+				chosen = true
+				break
+			}
+		}
+		if !chosen {
+			groups = append(groups, &p.groups[i])
+		}
+	}
+	return groups
+}
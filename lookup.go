@@ -0,0 +1,118 @@
+package main
+
+import (
+	"go-rti-testing/pkg/errors"
+)
+
+// Maximum number of condition sets checked by a price lookup.
+const maxLookupCombinations = 100000
+
+// PriceLookup lists condition sets under which the COST price is selected for the component.
+type PriceLookup struct {
+	Component     string         `json:"component"`
+	Cost          float64        `json:"cost"`
+	ConditionSets []ConditionSet `json:"conditionSets"`
+}
+
+// ConditionSet is a set of conditions with the resulting discounted component cost.
+// Numeric values are representatives of ranges between the rule thresholds.
+type ConditionSet struct {
+	Conditions []Condition `json:"conditions"`
+	Cost       float64     `json:"cost"`
+}
+
+// Lookup returns the condition sets that make the COST price of the component apply
+// and be the selected one. Conditions of the codeNames used by the price and by the product
+// rules are always present, other codeNames of the component are given only when they are needed.
+func Lookup(product *Product, component string, cost float64) (*PriceLookup, error) {
+	compiled := Compile(product)
+	if compiled == nil {
+		return nil, errors.BadRequest.New("product is required")
+	}
+
+	var c *compiledComponent
+	for i := range compiled.components {
+		if compiled.components[i].name == component {
+			c = &compiled.components[i]
+			break
+		}
+	}
+	if c == nil {
+		return nil, errors.BadRequest.Newf("unknown component: %s", component)
+	}
+
+	targets := make(map[int]bool)
+	required := make(map[string]bool)
+	for i, price := range c.prices {
+		if price.priceType == PriceTypeCost && price.cost == cost {
+			targets[i] = true
+			for name := range price.rules {
+				required[name] = true
+			}
+		}
+	}
+	if len(targets) == 0 {
+		return nil, errors.BadRequest.Newf("component %s has no COST price %v", component, cost)
+	}
+
+	// Product rules decide whether there is an offer at all, so their codeNames are always given.
+	for name := range compiled.rules {
+		required[name] = true
+	}
+	prices := append([]compiledPrice{{rules: compiled.rules}}, c.prices...)
+	options := newConditionOptions(representativeValues(prices), ruleNames(prices), func(name string) bool {
+		return !required[name]
+	})
+	if countCombinations(options, maxLookupCombinations) > maxLookupCombinations {
+		return nil, errors.BadRequest.Newf("too many condition combinations, maximum is %d", maxLookupCombinations)
+	}
+
+	var sets []ConditionSet
+	var found [][]compiledCondition
+	forEachCombination(options, func(conditions []compiledCondition) {
+		if match, err := compiled.rules.check(conditions); err != nil || !match {
+			return
+		}
+		selected, cost, err := c.validate(conditions)
+		if err != nil || !targets[selected] {
+			return
+		}
+		found = append(found, append([]compiledCondition(nil), conditions...))
+		sets = append(sets, ConditionSet{Conditions: toConditions(conditions, options), Cost: cost})
+	})
+
+	lookup := &PriceLookup{Component: component, Cost: cost, ConditionSets: []ConditionSet{}}
+	for i := range sets {
+		if !extendsAny(found[i], found) {
+			lookup.ConditionSets = append(lookup.ConditionSets, sets[i])
+		}
+	}
+
+	return lookup, nil
+}
+
+// Function reports whether the conditions contain all conditions of a smaller found set.
+func extendsAny(conditions []compiledCondition, found [][]compiledCondition) bool {
+	for _, other := range found {
+		if len(other) < len(conditions) && containsConditions(conditions, other) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsConditions(conditions, subset []compiledCondition) bool {
+	for _, s := range subset {
+		contains := false
+		for _, c := range conditions {
+			if c.name == s.name && c.value == s.value {
+				contains = true
+				break
+			}
+		}
+		if !contains {
+			return false
+		}
+	}
+	return true
+}
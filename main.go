@@ -3,37 +3,164 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"flag"
 	"fmt"
 	"log"
+	"mime"
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/felixge/httpsnoop"
+	"gopkg.in/yaml.v3"
 
 	"go-rti-testing/pkg/errors"
+	"go-rti-testing/pkg/sign"
 )
 
+// CalculateRequest takes either an inline product or the ID of a catalog product.
+// A catalog product can be priced as of a past version or time.
+// The request is accepted in JSON and YAML. ID is echoed back by batch calculations.
 type CalculateRequest struct {
-	Product    Product     `json:"product"`
+	ID         string              `json:"id,omitempty" yaml:"id,omitempty"`
+	Product    *Product            `json:"product,omitempty" yaml:"product,omitempty"`
+	ProductID  string              `json:"productId,omitempty" yaml:"productId,omitempty"`
+	Version    int                 `json:"version,omitempty" yaml:"version,omitempty"`
+	AsOf       *time.Time          `json:"asOf,omitempty" yaml:"asOf,omitempty"`
+	Conditions []Condition         `json:"conditions" yaml:"conditions"`
+	Selection  *ComponentSelection `json:"components,omitempty" yaml:"components,omitempty"`
+}
+
+// BestRequest takes inline products and IDs of catalog products.
+type BestRequest struct {
+	Products   []Product   `json:"products,omitempty"`
+	ProductIDs []string    `json:"productIds,omitempty"`
 	Conditions []Condition `json:"conditions"`
 }
 
+type AnalyzeRequest struct {
+	Product Product `json:"product"`
+}
+
+type LookupRequest struct {
+	Product   Product `json:"product"`
+	Component string  `json:"component"`
+	Cost      float64 `json:"cost"`
+}
+
+type EnumerateRequest struct {
+	Product Product           `json:"product"`
+	Domains []ConditionDomain `json:"domains"`
+}
+
 type ErrorResponse struct {
 	Error string `json:"error"`
 }
 
+// The command is serve if the first argument is missing or is a flag,
+// so the server keeps starting with flags only.
 func main() {
-	mux := http.NewServeMux()
-	mux.HandleFunc("/ping", ping)
-	mux.HandleFunc("/calculate", calculate)
+	command, args := "serve", os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		command, args = args[0], args[1:]
+	}
+
+	switch command {
+	case "serve":
+		os.Exit(runServe(args))
+	case "calculate":
+		os.Exit(runCalculate(args, os.Stdin, os.Stdout, os.Stderr))
+	case "replay":
+		os.Exit(runReplay(args, os.Stdin, os.Stdout, os.Stderr))
+	case "diff":
+		os.Exit(runDiff(args, os.Stdout, os.Stderr))
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown command %s, expected serve, calculate, replay or diff\n", command)
+		os.Exit(2)
+	}
+}
+
+// Function runs the HTTP server until SIGINT.
+func runServe(args []string) int {
+	flags := flag.NewFlagSet("serve", flag.ContinueOnError)
+	addr := flags.String("addr", ":8080", "HTTP listen address")
+	catalogDir := flags.String("catalog", "", "directory of product JSON, YAML and CSV files")
+	catalogPoll := flags.Duration("catalog-poll", 5*time.Second, "interval of catalog directory checks, 0 disables them")
+	dataFile := flags.String("data", "", "storage file of catalog versions and quotes, empty keeps them in memory")
+	quoteTTL := flags.Duration("quote-ttl", 24*time.Hour, "time an issued quote can be accepted")
+	quotePurge := flags.Duration("quote-purge", time.Hour, "interval of removing expired quotes which were not accepted, 0 disables it")
+	signKey := flags.String("sign-key", "", "key file for signing quotes, empty disables signing")
+	signAlg := flags.String("sign-alg", sign.HMAC, "quote signing algorithm: hmac or ed25519")
+	batchWorkers := flags.Int("batch-workers", defaultBatchWorkers, "number of concurrent calculations of a batch")
+	batchMax := flags.Int("batch-max", defaultBatchMax, "maximum number of requests in a batch")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
 
-	srv := http.Server{Addr: ":8080", Handler: logRequest(mux)}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	storage, err := openStorage(*dataFile)
+	if err != nil {
+		log.Fatalf("Open storage: %v", err)
+	}
+	defer storage.Close()
+
+	var signer sign.Signer
+	if *signKey != "" {
+		if signer, err = sign.LoadKey(*signAlg, *signKey); err != nil {
+			log.Fatalf("Load sign key: %v", err)
+		}
+		if !signer.CanSign() {
+			log.Fatalf("Load sign key: %s is a public key, signing quotes needs a private key", *signKey)
+		}
+	}
+
+	var catalogs *CatalogStore
+	if *catalogDir != "" {
+		if catalogs, err = NewCatalogStore(*catalogDir, storage); err != nil {
+			log.Fatalf("Load catalog: %v", err)
+		}
+		log.Printf("Loaded %d products from %s", len(catalogs.Catalog().IDs()), *catalogDir)
+
+		if *catalogPoll > 0 {
+			go catalogs.Watch(ctx, *catalogPoll)
+		}
+		go func() {
+			sighup := make(chan os.Signal, 1)
+			signal.Notify(sighup, syscall.SIGHUP)
+			for range sighup {
+				catalogs.ReloadAndLog()
+			}
+		}()
+	}
+
+	if *batchWorkers < 1 {
+		log.Fatalf("Invalid -batch-workers: %d", *batchWorkers)
+	}
+	if *batchMax < 1 {
+		log.Fatalf("Invalid -batch-max: %d", *batchMax)
+	}
+
+	quotes := NewQuoteStore(storage, *quoteTTL, signer)
+	if *quotePurge > 0 {
+		go quotes.PurgeExpired(ctx, *quotePurge)
+	}
+
+	handler := newServer(catalogs, quotes)
+	handler.batchWorkers = *batchWorkers
+	handler.batchMax = *batchMax
+
+	srv := http.Server{Addr: *addr, Handler: logRequest(handler.routes())}
 	idleConnsClosed := make(chan struct{})
 	go func() {
 		sigint := make(chan os.Signal, 1)
 		signal.Notify(sigint, os.Interrupt)
 		<-sigint
+		cancel()
 
 		if err := srv.Shutdown(context.Background()); err != nil {
 			log.Printf("HTTP server Shutdown: %v", err)
@@ -41,12 +168,13 @@ func main() {
 		close(idleConnsClosed)
 	}()
 
-	log.Println("Starting http server on :8080...")
+	log.Printf("Starting http server on %s...", *addr)
 	if err := srv.ListenAndServe(); err != http.ErrServerClosed {
 		log.Fatalf("HTTP server ListenAndServe: %v", err)
 	}
 
 	<-idleConnsClosed
+	return 0
 }
 
 func logRequest(handler http.Handler) http.Handler {
@@ -72,6 +200,60 @@ func decodeJson(req *http.Request, dst interface{}) error {
 	return nil
 }
 
+// Function decodes the JSON or YAML request body.
+func decodeBody(req *http.Request, dst interface{}) error {
+	mediaType, _, _ := mime.ParseMediaType(req.Header.Get("Content-Type"))
+	switch mediaType {
+	case "application/json":
+		return decodeJson(req, dst)
+	case "application/yaml", "application/x-yaml", "text/yaml":
+		if err := yaml.NewDecoder(req.Body).Decode(dst); err != nil {
+			return errors.BadRequest.New("invalid request body")
+		}
+		return nil
+	default:
+		return errors.UnsupportedMediaType.New("application/json' or 'application/yaml")
+	}
+}
+
+// Function encodes the response in YAML if the client accepts it, otherwise in JSON.
+func encodeBody(w http.ResponseWriter, req *http.Request, v interface{}) error {
+	contentType, data, err := marshalBody(req, v)
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", contentType)
+	_, _ = w.Write(data)
+	return nil
+}
+
+func marshalBody(req *http.Request, v interface{}) (string, []byte, error) {
+	if acceptsYAML(req) {
+		data, err := yaml.Marshal(v)
+		if err != nil {
+			return "", nil, errors.Internal.Wrap(err, "yaml.Marshal(v)")
+		}
+		return "application/yaml", data, nil
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", nil, errors.Internal.Wrap(err, "json.Marshal(v)")
+	}
+	return "application/json", append(data, '\n'), nil
+}
+
+func acceptsYAML(req *http.Request) bool {
+	for _, accept := range strings.Split(req.Header.Get("Accept"), ",") {
+		mediaType, _, _ := mime.ParseMediaType(strings.TrimSpace(accept))
+		switch mediaType {
+		case "application/yaml", "application/x-yaml", "text/yaml":
+			return true
+		}
+	}
+	return false
+}
+
 func encodeJson(w http.ResponseWriter, v interface{}) error {
 	w.Header().Set("Content-Type", "application/json")
 	if err := json.NewEncoder(w).Encode(v); err != nil {
@@ -87,50 +269,36 @@ func newErrorResponse(message string) *ErrorResponse {
 }
 
 func httpError(w http.ResponseWriter, err error) {
-	var status int
+	status, message := errorStatus(err)
+	if message == "" {
+		w.WriteHeader(status)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(newErrorResponse(message))
+}
+
+// Function maps the error type to the HTTP status and the message shown to clients.
+// Internal errors are logged and their messages are hidden.
+func errorStatus(err error) (int, string) {
 	switch errors.GetType(err) {
 	case errors.UnsupportedMediaType:
-		status = http.StatusUnsupportedMediaType
-		_ = encodeJson(w, newErrorResponse(fmt.Sprintf(errors.MsgUnsupportedMediaType, err.Error())))
+		return http.StatusUnsupportedMediaType, fmt.Sprintf(errors.MsgUnsupportedMediaType, err.Error())
 	case errors.MethodNotAllowed:
-		status = http.StatusMethodNotAllowed
+		return http.StatusMethodNotAllowed, ""
 	case errors.BadRequest:
-		status = http.StatusBadRequest
-		_ = encodeJson(w, newErrorResponse(err.Error()))
+		return http.StatusBadRequest, err.Error()
+	case errors.NotFound:
+		return http.StatusNotFound, err.Error()
+	case errors.Conflict:
+		return http.StatusConflict, err.Error()
+	case errors.PreconditionFailed:
+		return http.StatusPreconditionFailed, err.Error()
+	case errors.Gone:
+		return http.StatusGone, err.Error()
 	default:
-		status = http.StatusInternalServerError
 		log.Printf("ERROR %s", err)
-		_ = encodeJson(w, newErrorResponse(http.StatusText(http.StatusInternalServerError)))
-	}
-	w.WriteHeader(status)
-}
-
-func ping(w http.ResponseWriter, _ *http.Request) {
-	_, _ = fmt.Fprint(w, "pong")
-}
-
-func calculate(w http.ResponseWriter, req *http.Request) {
-	if req.Method != http.MethodPost {
-		httpError(w, errors.MethodNotAllowed.New(""))
-		return
-	}
-
-	var calcReq CalculateRequest
-	if err := decodeJson(req, &calcReq); err != nil {
-		httpError(w, err)
-		return
-	}
-
-	offer, err := Calculate(&calcReq.Product, calcReq.Conditions)
-	if err != nil {
-		httpError(w, err)
-		return
-	}
-
-	if offer != nil {
-		if err := encodeJson(w, offer); err != nil {
-			httpError(w, err)
-			return
-		}
+		return http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError)
 	}
 }
@@ -1,6 +1,12 @@
 package main
 
-import "testing"
+import (
+	"reflect"
+	"strconv"
+	"testing"
+
+	"go-rti-testing/pkg/errors"
+)
 
 var product = Product{
 	Name: "Игровой",
@@ -392,3 +398,1119 @@ func TestCalculateDiscount2(t *testing.T) {
 		}
 	}
 }
+
+func TestCalculatePatternOperators(t *testing.T) {
+	p := Product{
+		Name: "Домашний",
+		Components: []Component{
+			{
+				IsMain: true,
+				Name:   "Интернет",
+				Prices: []Price{
+					{
+						Cost:      300,
+						PriceType: PriceTypeCost,
+						RuleApplicabilities: []RuleApplicability{
+							{CodeName: "postalCode", Operator: OperatorStartsWith, Value: "10"},
+							{CodeName: "plan", Operator: OperatorMatches, Value: "home-[0-9]+"},
+						},
+					},
+					{
+						Cost:      20,
+						PriceType: PriceTypeDiscount,
+						RuleApplicabilities: []RuleApplicability{
+							{CodeName: "plan", Operator: OperatorEndsWith, Value: "-2"},
+						},
+					},
+					{
+						Cost:      50,
+						PriceType: PriceTypeDiscount,
+						RuleApplicabilities: []RuleApplicability{
+							{CodeName: "promo", Operator: OperatorContains, Value: "SALE"},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	r, err := Calculate(&p, []Condition{
+		{RuleName: "postalCode", Value: "101000"},
+		{RuleName: "plan", Value: "home-2"},
+		{RuleName: "promo", Value: "AUTUMN-SALE-1"},
+	})
+	if err != nil {
+		t.Error("Error calculating", err)
+		return
+	}
+	if r == nil {
+		t.Error("Неверно расчитанно предложение")
+		return
+	}
+	if r.TotalCost.Cost != 150 {
+		t.Error("Неверно расчитана сумма с учетом скидки")
+	}
+
+	r, err = Calculate(&p, []Condition{
+		{RuleName: "postalCode", Value: "201000"},
+		{RuleName: "plan", Value: "home-2"},
+	})
+	if err != nil {
+		t.Error("Error calculating", err)
+		return
+	}
+	if r != nil {
+		t.Error("Индекс не подходит под условие STARTS_WITH")
+	}
+
+	r, err = Calculate(&p, []Condition{
+		{RuleName: "postalCode", Value: "101000"},
+		{RuleName: "plan", Value: "office-home-2"},
+	})
+	if err != nil {
+		t.Error("Error calculating", err)
+		return
+	}
+	if r != nil {
+		t.Error("Шаблон MATCHES должен совпадать со всем значением")
+	}
+}
+
+func TestCalculateInvalidPattern(t *testing.T) {
+	p := Product{
+		Name: "Домашний",
+		Components: []Component{
+			{
+				IsMain: true,
+				Name:   "Интернет",
+				Prices: []Price{
+					{
+						Cost:      300,
+						PriceType: PriceTypeCost,
+						RuleApplicabilities: []RuleApplicability{
+							{CodeName: "plan", Operator: OperatorMatches, Value: "home-[0-9"},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	_, err := Calculate(&p, []Condition{{RuleName: "plan", Value: "home-1"}})
+	if errors.GetType(err) != errors.BadRequest {
+		t.Error("Некорректный шаблон должен возвращать ошибку валидации", err)
+	}
+}
+
+func TestCalculateMultiValued(t *testing.T) {
+	r, err := Calculate(&product, []Condition{
+		{
+			RuleName: "technology",
+			Values:   []string{"adsl", "xpon", "fttb"},
+		},
+		{
+			RuleName: "internetSpeed",
+			Values:   []string{"15", "50", "200"},
+		},
+	})
+	if err != nil {
+		t.Error("Error calculating", err)
+		return
+	}
+	if r == nil {
+		t.Error("Неверно расчитанно предложение")
+		return
+	}
+	if r.TotalCost.Cost != 360 {
+		t.Error("Должно быть выбрано самое дешевое предложение", r.TotalCost.Cost)
+	}
+}
+
+func TestCalculateDuplicateRuleNames(t *testing.T) {
+	r, err := Calculate(&product, []Condition{
+		{
+			RuleName: "technology",
+			Value:    "adsl",
+		},
+		{
+			RuleName: "Technology",
+			Value:    "xpon",
+		},
+		{
+			RuleName: "internetSpeed",
+			Value:    "200",
+		},
+	})
+	if err != nil {
+		t.Error("Error calculating", err)
+		return
+	}
+	if r == nil {
+		t.Error("Неверно расчитанно предложение")
+		return
+	}
+	if r.TotalCost.Cost != 765 {
+		t.Error("Повторяющиеся условия должны объединяться", r.TotalCost.Cost)
+	}
+}
+
+func TestCalculateRuleRange(t *testing.T) {
+	p := Product{
+		Name: "Скоростной",
+		Components: []Component{
+			{
+				IsMain: true,
+				Name:   "Интернет",
+				Prices: []Price{
+					{
+						Cost:      100,
+						PriceType: PriceTypeCost,
+						RuleApplicabilities: []RuleApplicability{
+							{CodeName: "internetSpeed", Operator: OperatorGreaterThanOrEqual, Value: "10"},
+							{CodeName: "internetSpeed", Operator: OperatorLessThanOrEqual, Value: "50"},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	for speed, available := range map[string]bool{"5": false, "10": true, "30": true, "50": true, "60": false} {
+		r, err := Calculate(&p, []Condition{{RuleName: "internetSpeed", Value: speed}})
+		if err != nil {
+			t.Error("Error calculating", err)
+			return
+		}
+		if (r != nil) != available {
+			t.Error("Все правила одного codeName в цене должны выполняться", speed, r)
+		}
+	}
+}
+
+func TestCalculateTooManyCombinations(t *testing.T) {
+	var conditions []Condition
+	for _, name := range []string{"a", "b", "c", "d"} {
+		conditions = append(conditions, Condition{
+			RuleName: name,
+			Values:   []string{"1", "2", "3", "4", "5", "6"},
+		})
+	}
+
+	_, err := Calculate(&product, conditions)
+	if errors.GetType(err) != errors.BadRequest {
+		t.Error("Слишком много комбинаций условий", err)
+	}
+}
+
+// Catalog with thousands of prices: every component has a price
+// for each technology and speed, plus speed discounts.
+func largeProduct() *Product {
+	technologies := []string{"adsl", "xpon", "fttb", "docsis", "lte"}
+	p := &Product{Name: "Большой"}
+
+	for c := 0; c < 20; c++ {
+		component := Component{Name: "Компонент " + strconv.Itoa(c), IsMain: c == 0}
+		for _, technology := range technologies {
+			for speed := 10; speed <= 500; speed += 10 {
+				component.Prices = append(component.Prices, Price{
+					Cost:      float64(speed + c),
+					PriceType: PriceTypeCost,
+					RuleApplicabilities: []RuleApplicability{
+						{CodeName: "technology", Operator: OperatorEqual, Value: technology},
+						{CodeName: "internetSpeed", Operator: OperatorEqual, Value: strconv.Itoa(speed)},
+					},
+				})
+			}
+		}
+		for speed := 100; speed <= 500; speed += 100 {
+			component.Prices = append(component.Prices, Price{
+				Cost:      float64(speed / 50),
+				PriceType: PriceTypeDiscount,
+				RuleApplicabilities: []RuleApplicability{
+					{CodeName: "internetSpeed", Operator: OperatorGreaterThanOrEqual, Value: strconv.Itoa(speed)},
+					{CodeName: "region", Operator: OperatorMatches, Value: "RU-[A-Z]+"},
+				},
+			})
+		}
+		p.Components = append(p.Components, component)
+	}
+
+	return p
+}
+
+var largeConditions = []Condition{
+	{RuleName: "technology", Value: "fttb"},
+	{RuleName: "internetSpeed", Value: "300"},
+	{RuleName: "region", Value: "RU-MOW"},
+}
+
+func TestCompiledProductMatchesCalculate(t *testing.T) {
+	p := largeProduct()
+	compiled := Compile(p)
+
+	r1, err := Calculate(p, largeConditions)
+	if err != nil {
+		t.Error("Error calculating", err)
+		return
+	}
+	r2, err := compiled.Calculate(largeConditions)
+	if err != nil {
+		t.Error("Error calculating", err)
+		return
+	}
+	if !reflect.DeepEqual(r1, r2) {
+		t.Error("Результаты расчета должны совпадать", r1, r2)
+	}
+	if r1 == nil || len(r1.Components) != 20 {
+		t.Error("Должно быть 20 компонентов")
+	}
+}
+
+func BenchmarkCalculate(b *testing.B) {
+	p := largeProduct()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := Calculate(p, largeConditions); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkCompiledCalculate(b *testing.B) {
+	compiled := Compile(largeProduct())
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := compiled.Calculate(largeConditions); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestAnalyze(t *testing.T) {
+	p := Product{
+		Name: "Игровой",
+		Components: []Component{
+			{
+				IsMain: true,
+				Name:   "Интернет",
+				Prices: []Price{
+					{
+						Cost:      500,
+						PriceType: PriceTypeCost,
+						RuleApplicabilities: []RuleApplicability{
+							{CodeName: "technology", Operator: OperatorEqual, Value: "xpon"},
+							{CodeName: "internetSpeed", Operator: OperatorGreaterThanOrEqual, Value: "100"},
+						},
+					},
+					{
+						Cost:      900,
+						PriceType: PriceTypeCost,
+						RuleApplicabilities: []RuleApplicability{
+							{CodeName: "technology", Operator: OperatorEqual, Value: "xpon"},
+							{CodeName: "internetSpeed", Operator: OperatorLessThanOrEqual, Value: "200"},
+						},
+					},
+					{
+						Cost:      100,
+						PriceType: PriceTypeCost,
+						RuleApplicabilities: []RuleApplicability{
+							{CodeName: "internetSpeed", Operator: OperatorGreaterThanOrEqual, Value: "100"},
+							{CodeName: "internetSpeed", Operator: OperatorLessThanOrEqual, Value: "50"},
+						},
+					},
+					{
+						Cost:      10,
+						PriceType: PriceTypeDiscount,
+						RuleApplicabilities: []RuleApplicability{
+							{CodeName: "technology", Operator: OperatorEqual, Value: "adsl"},
+						},
+					},
+					{
+						Cost:      15,
+						PriceType: PriceTypeDiscount,
+						RuleApplicabilities: []RuleApplicability{
+							{CodeName: "internetSpeed", Operator: OperatorGreaterThanOrEqual, Value: "150"},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	analysis := Analyze(&p)
+	if len(analysis.Issues) != 3 {
+		t.Error("Должно быть найдено 3 проблемы", analysis.Issues)
+		return
+	}
+
+	issue := analysis.Issues[0]
+	if issue.Type != IssueUnsatisfiable || issue.Prices[0] != 2 || issue.CodeName != "internetSpeed" {
+		t.Error("Цена с невыполнимыми правилами не найдена", issue)
+	}
+
+	issue = analysis.Issues[1]
+	if issue.Type != IssueOverlappingCost || !reflect.DeepEqual(issue.Prices, []int{0, 1}) {
+		t.Error("Пересекающиеся цены не найдены", issue)
+	}
+	r, err := Calculate(&p, issue.Conditions)
+	if err != nil || r != nil {
+		t.Error("Условия пересечения должны отклонять компонент", issue.Conditions, r, err)
+	}
+
+	issue = analysis.Issues[2]
+	if issue.Type != IssueUnreachableDiscount || issue.Prices[0] != 3 {
+		t.Error("Недостижимая скидка не найдена", issue)
+	}
+}
+
+func TestAnalyzeNoIssues(t *testing.T) {
+	analysis := Analyze(&product)
+	if len(analysis.Issues) != 0 {
+		t.Error("В продукте не должно быть проблем", analysis.Issues)
+	}
+}
+
+func TestSolveRulesPatterns(t *testing.T) {
+	p := Compile(&Product{Components: []Component{{Prices: []Price{{
+		RuleApplicabilities: []RuleApplicability{
+			{CodeName: "postalCode", Operator: OperatorStartsWith, Value: "10"},
+			{CodeName: "postalCode", Operator: OperatorMatches, Value: "[0-9]{6}"},
+			{CodeName: "plan", Operator: OperatorStartsWith, Value: "home"},
+			{CodeName: "plan", Operator: OperatorStartsWith, Value: "office"},
+		},
+	}}}}})
+	rules := p.components[0].prices[0].rules
+
+	if _, result := solveRules(rules["postalcode"]); result == unsatisfiable {
+		t.Error("Правила индекса выполнимы")
+	}
+	if _, result := solveRules(rules["plan"]); result != unsatisfiable {
+		t.Error("Правила тарифа невыполнимы")
+	}
+}
+
+func TestLookup(t *testing.T) {
+	r, err := Lookup(&product, "Интернет", 500)
+	if err != nil {
+		t.Error("Error lookup", err)
+		return
+	}
+
+	expected := []ConditionSet{
+		{
+			Conditions: []Condition{
+				{RuleName: "internetSpeed", Value: "100"},
+				{RuleName: "technology", Value: "xpon"},
+			},
+			Cost: 425,
+		},
+	}
+	if !reflect.DeepEqual(r.ConditionSets, expected) {
+		t.Error("Неверно найдены условия для цены", r.ConditionSets)
+	}
+
+	if _, err := Lookup(&product, "Телевидение", 500); errors.GetType(err) != errors.BadRequest {
+		t.Error("Неизвестный компонент должен возвращать ошибку", err)
+	}
+	if _, err := Lookup(&product, "Интернет", 10); errors.GetType(err) != errors.BadRequest {
+		t.Error("Скидка не является ценой COST", err)
+	}
+}
+
+func TestLookupRanges(t *testing.T) {
+	p := Product{
+		Name: "Домашний",
+		Components: []Component{
+			{
+				IsMain: true,
+				Name:   "Интернет",
+				Prices: []Price{
+					{
+						Cost:      300,
+						PriceType: PriceTypeCost,
+						RuleApplicabilities: []RuleApplicability{
+							{CodeName: "internetSpeed", Operator: OperatorLessThanOrEqual, Value: "100"},
+						},
+					},
+					{
+						Cost:      500,
+						PriceType: PriceTypeCost,
+						RuleApplicabilities: []RuleApplicability{
+							{CodeName: "internetSpeed", Operator: OperatorGreaterThanOrEqual, Value: "100"},
+							{CodeName: "promo", Operator: OperatorEqual, Value: "no"},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	r, err := Lookup(&p, "Интернет", 500)
+	if err != nil {
+		t.Error("Error lookup", err)
+		return
+	}
+
+	for _, set := range r.ConditionSets {
+		offer, err := Calculate(&p, set.Conditions)
+		if err != nil || offer == nil || offer.TotalCost.Cost != 500 {
+			t.Error("Условия должны приводить к цене 500", set.Conditions, offer, err)
+		}
+	}
+	if len(r.ConditionSets) != 1 {
+		t.Error("Должен быть 1 набор условий", r.ConditionSets)
+	}
+}
+
+func TestLookupProductRules(t *testing.T) {
+	p := product
+	p.RuleApplicabilities = []RuleApplicability{
+		{CodeName: "region", Operator: OperatorEqual, Value: "msk"},
+	}
+
+	r, err := Lookup(&p, "Интернет", 500)
+	if err != nil {
+		t.Error("Error lookup", err)
+		return
+	}
+	expected := []Condition{
+		{RuleName: "internetSpeed", Value: "100"},
+		{RuleName: "region", Value: "msk"},
+		{RuleName: "technology", Value: "xpon"},
+	}
+	if len(r.ConditionSets) != 1 || !reflect.DeepEqual(r.ConditionSets[0].Conditions, expected) {
+		t.Error("Набор условий должен содержать условие правила продукта", r.ConditionSets)
+	}
+
+	for _, set := range r.ConditionSets {
+		offer, err := Calculate(&p, set.Conditions)
+		if err != nil || offer == nil {
+			t.Error("Условия должны выполнять правила продукта", set.Conditions, offer, err)
+		}
+	}
+}
+
+func TestEnumerate(t *testing.T) {
+	from, to := 10.0, 200.0
+	r, err := Enumerate(&product, []ConditionDomain{
+		{
+			RuleName: "technology",
+			Values:   []string{"adsl", "xpon", "fttb"},
+		},
+		{
+			RuleName: "internetSpeed",
+			From:     &from,
+			To:       &to,
+		},
+	})
+	if err != nil {
+		t.Error("Error enumerating", err)
+		return
+	}
+
+	costs := make([]float64, 0, len(r.Offers))
+	for _, o := range r.Offers {
+		costs = append(costs, o.Offer.TotalCost.Cost)
+		for _, set := range o.ConditionSets {
+			offer, err := Calculate(&product, set)
+			if err != nil || !reflect.DeepEqual(offer, o.Offer) {
+				t.Error("Условия должны приводить к предложению", set, offer, err)
+			}
+		}
+	}
+	if !reflect.DeepEqual(costs, []float64{400, 450, 200, 360, 425, 765, 510}) {
+		t.Error("Неверно найдены доступные предложения", costs)
+	}
+	if len(r.Unavailable) != 26 {
+		t.Error("Неверно найдены условия без предложения", len(r.Unavailable))
+	}
+
+	from = 300
+	if _, err := Enumerate(&product, []ConditionDomain{{RuleName: "internetSpeed", From: &from, To: &to}}); errors.GetType(err) != errors.BadRequest {
+		t.Error("Некорректный диапазон должен возвращать ошибку", err)
+	}
+}
+
+func TestEnumerateProductRules(t *testing.T) {
+	p := Product{
+		Name: "Скоростной",
+		RuleApplicabilities: []RuleApplicability{
+			{CodeName: "speed", Operator: OperatorGreaterThanOrEqual, Value: "20"},
+			{CodeName: "speed", Operator: OperatorLessThanOrEqual, Value: "30"},
+		},
+		Components: []Component{
+			{
+				IsMain: true,
+				Name:   "Интернет",
+				Prices: []Price{{Cost: 100, PriceType: PriceTypeCost}},
+			},
+		},
+	}
+
+	from, to := 10.0, 200.0
+	r, err := Enumerate(&p, []ConditionDomain{{RuleName: "speed", From: &from, To: &to}})
+	if err != nil {
+		t.Error("Error enumerating", err)
+		return
+	}
+	if len(r.Offers) != 1 || r.Offers[0].Offer.TotalCost.Cost != 100 {
+		t.Error("Диапазон правил продукта должен давать предложение", r.Offers)
+		return
+	}
+	for _, set := range r.Offers[0].ConditionSets {
+		offer, err := Calculate(&p, set)
+		if err != nil || offer == nil {
+			t.Error("Условия должны приводить к предложению", set, err)
+		}
+	}
+}
+
+func TestBest(t *testing.T) {
+	cheap := Product{
+		Name: "Базовый",
+		Components: []Component{
+			{
+				IsMain: true,
+				Name:   "Интернет",
+				Prices: []Price{
+					{
+						Cost:      300,
+						PriceType: PriceTypeCost,
+						RuleApplicabilities: []RuleApplicability{
+							{CodeName: "technology", Operator: OperatorEqual, Value: "xpon"},
+						},
+					},
+				},
+			},
+		},
+	}
+	fttb := Product{
+		Name: "FTTB",
+		Components: []Component{
+			{
+				IsMain: true,
+				Name:   "Интернет",
+				Prices: []Price{
+					{
+						Cost:      200,
+						PriceType: PriceTypeCost,
+						RuleApplicabilities: []RuleApplicability{
+							{CodeName: "technology", Operator: OperatorEqual, Value: "fttb"},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	r := Best([]*CompiledProduct{Compile(&product), Compile(&fttb), Compile(&cheap)}, []Condition{
+		{RuleName: "technology", Value: "xpon"},
+		{RuleName: "internetSpeed", Value: "200"},
+	})
+	if len(r.Offers) != 2 || r.Offers[0].Name != "Базовый" || r.Offers[1].Name != "Игровой" {
+		t.Error("Предложения должны быть отсортированы по стоимости", r.Offers)
+	}
+	expected := []Rejection{{Product: "FTTB", Reason: "main component Интернет is not available"}}
+	if !reflect.DeepEqual(r.Rejected, expected) {
+		t.Error("Неверно указаны причины отказа", r.Rejected)
+	}
+}
+
+func TestCalculateSelection(t *testing.T) {
+	adsl := []Condition{
+		{RuleName: "technology", Value: "adsl"},
+		{RuleName: "internetSpeed", Value: "10"},
+	}
+	compiled := Compile(&product)
+
+	r, _, err := compiled.calculate(adsl, &ComponentSelection{Exclude: []string{"ADSL Модем"}})
+	if err != nil {
+		t.Error("Error calculating", err)
+		return
+	}
+	if r == nil || len(r.Components) != 1 || r.TotalCost.Cost != 100 {
+		t.Error("Исключенный компонент не должен входить в предложение", r)
+	}
+
+	r, _, err = compiled.calculate(adsl, &ComponentSelection{Include: []string{}})
+	if err != nil {
+		t.Error("Error calculating", err)
+		return
+	}
+	if r == nil || len(r.Components) != 1 {
+		t.Error("В предложение должны входить только обязательные компоненты", r)
+	}
+
+	r, _, err = compiled.calculate(adsl, &ComponentSelection{Include: []string{"ADSL Модем"}})
+	if err != nil {
+		t.Error("Error calculating", err)
+		return
+	}
+	if r == nil || r.TotalCost.Cost != 400 {
+		t.Error("Выбранный компонент должен входить в предложение", r)
+	}
+
+	errs := []*ComponentSelection{
+		{Include: []string{"Телевидение"}},
+		{Exclude: []string{"Интернет"}},
+		{Include: []string{"ADSL Модем"}, Exclude: []string{"ADSL Модем"}},
+	}
+	for _, selection := range errs {
+		if _, _, err := compiled.calculate(adsl, selection); errors.GetType(err) != errors.BadRequest {
+			t.Error("Некорректный выбор компонентов должен возвращать ошибку", selection, err)
+		}
+	}
+
+	xpon := []Condition{
+		{RuleName: "technology", Value: "xpon"},
+		{RuleName: "internetSpeed", Value: "200"},
+	}
+	if _, _, err := compiled.calculate(xpon, &ComponentSelection{Include: []string{"ADSL Модем"}}); errors.GetType(err) != errors.BadRequest {
+		t.Error("Недоступный выбранный компонент должен возвращать ошибку", err)
+	}
+
+	multi := []Condition{
+		{RuleName: "technology", Values: []string{"xpon", "adsl"}},
+		{RuleName: "internetSpeed", Values: []string{"200", "10"}},
+	}
+	r, _, err = compiled.calculate(multi, &ComponentSelection{Include: []string{"ADSL Модем"}})
+	if err != nil {
+		t.Error("Error calculating", err)
+		return
+	}
+	if r == nil || r.TotalCost.Cost != 400 {
+		t.Error("Должно быть выбрано предложение с доступным компонентом", r)
+	}
+}
+
+func TestCalculateDependencies(t *testing.T) {
+	p := Product{
+		Name: "Домашний",
+		Components: []Component{
+			{
+				IsMain: true,
+				Name:   "Интернет",
+				Prices: []Price{{Cost: 500, PriceType: PriceTypeCost}},
+			},
+			{
+				Name: "ТВ пакет",
+				Prices: []Price{
+					{
+						Cost:      200,
+						PriceType: PriceTypeCost,
+						RuleApplicabilities: []RuleApplicability{
+							{CodeName: "technology", Operator: OperatorEqual, Value: "xpon"},
+						},
+					},
+				},
+			},
+			{
+				Name:     "ТВ приставка",
+				Requires: []string{"ТВ пакет"},
+				Prices:   []Price{{Cost: 100, PriceType: PriceTypeCost}},
+			},
+			{
+				Name:     "Статический IP",
+				Excludes: []string{"Антивирус"},
+				Prices:   []Price{{Cost: 150, PriceType: PriceTypeCost}},
+			},
+			{
+				Name:   "Антивирус",
+				Prices: []Price{{Cost: 50, PriceType: PriceTypeCost}},
+			},
+		},
+	}
+	compiled := Compile(&p)
+	adsl := []Condition{{RuleName: "technology", Value: "adsl"}}
+
+	r, _, err := compiled.calculate(adsl, nil)
+	if err != nil {
+		t.Error("Error calculating", err)
+		return
+	}
+	if r == nil || r.TotalCost.Cost != 550 {
+		t.Error("Неверно расчитана сумма", r)
+		return
+	}
+	expected := []ExcludedComponent{
+		{Name: "ТВ приставка", Reason: "requires ТВ пакет"},
+		{Name: "Статический IP", Reason: "excluded by Антивирус"},
+	}
+	if !reflect.DeepEqual(r.Excluded, expected) {
+		t.Error("Неверно объяснены исключенные компоненты", r.Excluded)
+	}
+
+	r, _, err = compiled.calculate(adsl, &ComponentSelection{Include: []string{"Статический IP", "Антивирус"}})
+	if errors.GetType(err) != errors.BadRequest {
+		t.Error("Взаимоисключающие выбранные компоненты должны возвращать ошибку", r, err)
+	}
+
+	r, _, err = compiled.calculate(adsl, &ComponentSelection{Include: []string{"Статический IP"}})
+	if err != nil {
+		t.Error("Error calculating", err)
+		return
+	}
+	if r == nil || r.TotalCost.Cost != 650 || r.Excluded != nil {
+		t.Error("Невыбранный компонент не должен исключать выбранный", r)
+	}
+
+	if _, _, err := compiled.calculate(adsl, &ComponentSelection{Include: []string{"ТВ приставка"}}); errors.GetType(err) != errors.BadRequest {
+		t.Error("Выбранный компонент без зависимостей должен возвращать ошибку", err)
+	}
+
+	r, _, err = compiled.calculate([]Condition{{RuleName: "technology", Value: "xpon"}}, nil)
+	if err != nil {
+		t.Error("Error calculating", err)
+		return
+	}
+	if r == nil || r.TotalCost.Cost != 850 {
+		t.Error("Неверно расчитана сумма", r)
+	}
+}
+
+func TestCalculateGroups(t *testing.T) {
+	p := Product{
+		Name: "Домашний",
+		Components: []Component{
+			{
+				IsMain: true,
+				Name:   "Интернет",
+				Prices: []Price{{Cost: 500, PriceType: PriceTypeCost}},
+			},
+			{
+				Name: "Роутер Wi-Fi 5",
+				Prices: []Price{
+					{
+						Cost:      150,
+						PriceType: PriceTypeCost,
+						RuleApplicabilities: []RuleApplicability{
+							{CodeName: "technology", Operator: OperatorEqual, Value: "xpon"},
+						},
+					},
+				},
+			},
+			{
+				Name:   "Роутер Wi-Fi 6",
+				Prices: []Price{{Cost: 250, PriceType: PriceTypeCost}},
+			},
+			{
+				Name:   "Роутер Mesh",
+				Prices: []Price{{Cost: 400, PriceType: PriceTypeCost}},
+			},
+			{
+				Name:   "Антивирус",
+				Prices: []Price{{Cost: 50, PriceType: PriceTypeCost}},
+			},
+		},
+		Groups: []ComponentGroup{
+			{
+				Name:       "Роутер",
+				Components: []string{"Роутер Wi-Fi 5", "Роутер Wi-Fi 6", "Роутер Mesh"},
+				Min:        1,
+				Max:        1,
+			},
+		},
+	}
+	compiled := Compile(&p)
+	xpon := []Condition{{RuleName: "technology", Value: "xpon"}}
+
+	r, _, err := compiled.calculate(xpon, nil)
+	if err != nil {
+		t.Error("Error calculating", err)
+		return
+	}
+	if r == nil || r.TotalCost.Cost != 700 || len(r.Excluded) != 2 {
+		t.Error("Должен быть выбран самый дешевый роутер", r)
+	}
+
+	r, _, err = compiled.calculate(xpon, &ComponentSelection{Include: []string{"Роутер Mesh"}})
+	if err != nil {
+		t.Error("Error calculating", err)
+		return
+	}
+	if r == nil || r.TotalCost.Cost != 900 {
+		t.Error("Должен быть выбран роутер пользователя", r)
+	}
+
+	r, _, err = compiled.calculate(xpon, &ComponentSelection{Include: []string{"Антивирус"}})
+	if err != nil {
+		t.Error("Error calculating", err)
+		return
+	}
+	if r == nil || r.TotalCost.Cost != 700 {
+		t.Error("Роутер должен выбираться по умолчанию", r)
+	}
+
+	_, _, err = compiled.calculate(xpon, &ComponentSelection{Include: []string{"Роутер Mesh", "Роутер Wi-Fi 6"}})
+	if errors.GetType(err) != errors.BadRequest {
+		t.Error("Выбор нескольких роутеров должен возвращать ошибку", err)
+	}
+
+	r, reason, err := compiled.calculate(xpon, &ComponentSelection{Exclude: []string{"Роутер Mesh", "Роутер Wi-Fi 6", "Роутер Wi-Fi 5"}})
+	if err != nil {
+		t.Error("Error calculating", err)
+		return
+	}
+	if r != nil || reason != "group Роутер has fewer than 1 available components" {
+		t.Error("Обязательная группа без компонентов должна исключать предложение", r, reason)
+	}
+}
+
+func TestCalculateGroupDependencies(t *testing.T) {
+	p := Product{
+		Name: "Домашний",
+		Components: []Component{
+			{
+				IsMain: true,
+				Name:   "Интернет",
+				Prices: []Price{{Cost: 500, PriceType: PriceTypeCost}},
+			},
+			{
+				Name: "ТВ",
+				Prices: []Price{
+					{
+						Cost:      300,
+						PriceType: PriceTypeCost,
+						RuleApplicabilities: []RuleApplicability{
+							{CodeName: "technology", Operator: OperatorEqual, Value: "xpon"},
+						},
+					},
+				},
+			},
+			{
+				Name:     "Роутер с ТВ",
+				Requires: []string{"ТВ"},
+				Prices:   []Price{{Cost: 100, PriceType: PriceTypeCost}},
+			},
+			{
+				Name:   "Роутер",
+				Prices: []Price{{Cost: 200, PriceType: PriceTypeCost}},
+			},
+		},
+		Groups: []ComponentGroup{
+			{
+				Name:       "Роутер",
+				Components: []string{"Роутер с ТВ", "Роутер"},
+				Min:        1,
+				Max:        1,
+			},
+		},
+	}
+	compiled := Compile(&p)
+
+	r, reason, err := compiled.calculate([]Condition{{RuleName: "technology", Value: "adsl"}}, nil)
+	if err != nil {
+		t.Error("Error calculating", err)
+		return
+	}
+	if r == nil || r.TotalCost.Cost != 700 {
+		t.Error("Должен быть выбран самый дешевый доступный роутер", r, reason)
+		return
+	}
+	if len(r.Excluded) != 1 || r.Excluded[0] != (ExcludedComponent{Name: "Роутер с ТВ", Reason: "requires ТВ"}) {
+		t.Error("Неверно указаны исключенные компоненты", r.Excluded)
+	}
+
+	r, _, err = compiled.calculate([]Condition{{RuleName: "technology", Value: "xpon"}}, nil)
+	if err != nil {
+		t.Error("Error calculating", err)
+		return
+	}
+	if r == nil || r.TotalCost.Cost != 900 {
+		t.Error("Роутер с ТВ должен выбираться, если ТВ доступно", r)
+	}
+}
+
+func TestCalculateGroupExclusion(t *testing.T) {
+	p := Product{
+		Name: "Домашний",
+		Components: []Component{
+			{IsMain: true, Name: "Интернет", Prices: []Price{{Cost: 500, PriceType: PriceTypeCost}}},
+			{Name: "Базовый", Prices: []Price{{Cost: 100, PriceType: PriceTypeCost}}},
+			{Name: "Премиум", Prices: []Price{{Cost: 200, PriceType: PriceTypeCost}}},
+			{Name: "Статический IP", Excludes: []string{"Базовый"}, Prices: []Price{{Cost: 50, PriceType: PriceTypeCost}}},
+		},
+		Groups: []ComponentGroup{
+			{Name: "Тариф", Components: []string{"Базовый", "Премиум"}, Min: 1, Max: 1},
+		},
+	}
+	compiled := Compile(&p)
+
+	r, reason, err := compiled.calculate(nil, &ComponentSelection{Include: []string{"Статический IP"}})
+	if err != nil {
+		t.Error("Error calculating", err)
+		return
+	}
+	if r == nil || r.TotalCost.Cost != 750 {
+		t.Error("Выбранный компонент должен вытеснять компонент группы по умолчанию", r, reason)
+	}
+
+	_, _, err = compiled.calculate(nil, &ComponentSelection{Include: []string{"Статический IP", "Базовый"}})
+	if errors.GetType(err) != errors.BadRequest {
+		t.Error("Выбор исключающих друг друга компонентов должен возвращать ошибку", err)
+	}
+}
+
+func TestCalculateBundles(t *testing.T) {
+	p := Product{
+		Name: "Домашний",
+		Components: []Component{
+			{
+				IsMain: true,
+				Name:   "Интернет",
+				Prices: []Price{{Cost: 500, PriceType: PriceTypeCost}},
+			},
+			{
+				Name: "ТВ",
+				Prices: []Price{
+					{
+						Cost:      300,
+						PriceType: PriceTypeCost,
+						RuleApplicabilities: []RuleApplicability{
+							{CodeName: "technology", Operator: OperatorEqual, Value: "xpon"},
+						},
+					},
+				},
+			},
+			{
+				Name:   "Телефон",
+				Prices: []Price{{Cost: 150, PriceType: PriceTypeCost}},
+			},
+		},
+		Bundles: []BundleDiscount{
+			{
+				Name:       "Интернет + ТВ",
+				Components: []string{"Интернет", "ТВ"},
+				Targets:    []string{"Интернет"},
+				Discount:   10,
+			},
+			{
+				Name:       "Все включено",
+				Components: []string{"Интернет", "ТВ", "Телефон"},
+				Amount:     200,
+			},
+		},
+	}
+
+	r, err := Calculate(&p, []Condition{{RuleName: "technology", Value: "xpon"}})
+	if err != nil {
+		t.Error("Error calculating", err)
+		return
+	}
+	if r == nil || r.TotalCost.Cost != 700 {
+		t.Error("Неверно расчитана сумма с учетом пакетных скидок", r)
+		return
+	}
+	if r.Components[0].Prices[0].Cost != 450 {
+		t.Error("Неверно расчитана цена компонента Интернет с учетом пакетной скидки")
+	}
+	expected := []AppliedBundle{{Name: "Интернет + ТВ", Saved: 50}, {Name: "Все включено", Saved: 200}}
+	if !reflect.DeepEqual(r.AppliedBundles, expected) {
+		t.Error("Неверно показаны пакетные скидки", r.AppliedBundles)
+	}
+
+	r, err = Calculate(&p, []Condition{{RuleName: "technology", Value: "adsl"}})
+	if err != nil {
+		t.Error("Error calculating", err)
+		return
+	}
+	if r == nil || r.TotalCost.Cost != 650 || r.AppliedBundles != nil {
+		t.Error("Пакетные скидки не должны применяться без ТВ", r)
+	}
+}
+
+func TestCalculateProductRules(t *testing.T) {
+	p := Product{
+		Name: "Смешанный",
+		RuleApplicabilities: []RuleApplicability{
+			{CodeName: "region", Operator: OperatorEqual, Value: "MOW"},
+		},
+		MainComponents: MainComponentsAny,
+		Components: []Component{
+			{
+				IsMain: true,
+				Name:   "Интернет xPON",
+				Prices: []Price{
+					{
+						Cost:      500,
+						PriceType: PriceTypeCost,
+						RuleApplicabilities: []RuleApplicability{
+							{CodeName: "technology", Operator: OperatorEqual, Value: "xpon"},
+						},
+					},
+				},
+			},
+			{
+				IsMain: true,
+				Name:   "Интернет ADSL",
+				Prices: []Price{
+					{
+						Cost:      300,
+						PriceType: PriceTypeCost,
+						RuleApplicabilities: []RuleApplicability{
+							{CodeName: "technology", Operator: OperatorEqual, Value: "adsl"},
+						},
+					},
+				},
+			},
+			{
+				Name:   "Антивирус",
+				Prices: []Price{{Cost: 50, PriceType: PriceTypeCost}},
+			},
+		},
+	}
+	compiled := Compile(&p)
+
+	r, _, err := compiled.calculate([]Condition{
+		{RuleName: "region", Value: "MOW"},
+		{RuleName: "technology", Value: "adsl"},
+	}, nil)
+	if err != nil {
+		t.Error("Error calculating", err)
+		return
+	}
+	if r == nil || len(r.Components) != 2 || r.TotalCost.Cost != 350 {
+		t.Error("Достаточно одного доступного основного компонента", r)
+	}
+
+	r, reason, err := compiled.calculate([]Condition{
+		{RuleName: "region", Value: "SPB"},
+		{RuleName: "technology", Value: "adsl"},
+	}, nil)
+	if err != nil {
+		t.Error("Error calculating", err)
+		return
+	}
+	if r != nil || reason != "product rules are not met" {
+		t.Error("Продукт недоступен в регионе", r, reason)
+	}
+
+	r, reason, err = compiled.calculate([]Condition{
+		{RuleName: "region", Value: "MOW"},
+		{RuleName: "technology", Value: "fttb"},
+	}, nil)
+	if err != nil {
+		t.Error("Error calculating", err)
+		return
+	}
+	if r != nil || reason != "none of the main components is available" {
+		t.Error("Должен быть доступен хотя бы один основной компонент", r, reason)
+	}
+
+	mow := []Condition{
+		{RuleName: "region", Value: "MOW"},
+		{RuleName: "technology", Value: "adsl"},
+	}
+	r, reason, err = compiled.calculate(mow, &ComponentSelection{Include: []string{"Антивирус"}})
+	if err != nil {
+		t.Error("Error calculating", err)
+		return
+	}
+	if r == nil || len(r.Components) != 2 || r.TotalCost.Cost != 350 {
+		t.Error("Выбор компонентов не должен отключать основные компоненты", r, reason)
+	}
+
+	if _, _, err := compiled.calculate(mow, &ComponentSelection{Exclude: []string{"Интернет ADSL"}}); errors.GetType(err) != errors.BadRequest {
+		t.Error("Основной компонент нельзя исключить", err)
+	}
+}
@@ -1,42 +1,113 @@
 package main
 
+import "time"
+
 const (
 	PriceTypeCost              = "COST"
 	PriceTypeDiscount          = "DISCOUNT"
 	OperatorEqual              = "EQ"
 	OperatorGreaterThanOrEqual = "GTE"
 	OperatorLessThanOrEqual    = "LTE"
+	OperatorMatches            = "MATCHES"
+	OperatorStartsWith         = "STARTS_WITH"
+	OperatorEndsWith           = "ENDS_WITH"
+	OperatorContains           = "CONTAINS"
+	MainComponentsAll          = "ALL"
+	MainComponentsAny          = "ANY"
 )
 
 type RuleApplicability struct {
-	CodeName string `json:"codeName"`
-	Operator string `json:"operator"`
-	Value    string `json:"value"`
+	CodeName string `json:"codeName" yaml:"codeName"`
+	Operator string `json:"operator" yaml:"operator"`
+	Value    string `json:"value" yaml:"value"`
 }
 
 type Price struct {
-	Cost                float64             `json:"cost"`
-	PriceType           string              `json:"priceType,omitempty"`
-	RuleApplicabilities []RuleApplicability `json:"ruleApplicabilities,omitempty"`
+	Cost                float64             `json:"cost" yaml:"cost"`
+	PriceType           string              `json:"priceType,omitempty" yaml:"priceType,omitempty"`
+	RuleApplicabilities []RuleApplicability `json:"ruleApplicabilities,omitempty" yaml:"ruleApplicabilities,omitempty"`
 }
 
+// Component can be offered only together with the components it requires
+// and never together with the components it excludes.
 type Component struct {
-	Name   string  `json:"name"`
-	IsMain bool    `json:"isMain,omitempty"`
-	Prices []Price `json:"prices"`
+	Name     string   `json:"name" yaml:"name"`
+	IsMain   bool     `json:"isMain,omitempty" yaml:"isMain,omitempty"`
+	Requires []string `json:"requires,omitempty" yaml:"requires,omitempty"`
+	Excludes []string `json:"excludes,omitempty" yaml:"excludes,omitempty"`
+	Prices   []Price  `json:"prices" yaml:"prices"`
+}
+
+// ComponentGroup limits how many of its components are offered.
+// Max 0 means no upper limit.
+type ComponentGroup struct {
+	Name       string   `json:"name" yaml:"name"`
+	Components []string `json:"components" yaml:"components"`
+	Min        int      `json:"min,omitempty" yaml:"min,omitempty"`
+	Max        int      `json:"max,omitempty" yaml:"max,omitempty"`
 }
 
+// BundleDiscount applies when all its components are in the offer.
+// Discount is a percentage and Amount is a sum taken off the cost
+// of every target component or, without targets, off the total cost.
+type BundleDiscount struct {
+	Name       string   `json:"name" yaml:"name"`
+	Components []string `json:"components" yaml:"components"`
+	Targets    []string `json:"targets,omitempty" yaml:"targets,omitempty"`
+	Discount   float64  `json:"discount,omitempty" yaml:"discount,omitempty"`
+	Amount     float64  `json:"amount,omitempty" yaml:"amount,omitempty"`
+}
+
+// Product is offered only when its rules are met. MainComponents tells
+// whether ALL main components (the default) or ANY of them must be available.
 type Product struct {
-	Name       string      `json:"name"`
-	Components []Component `json:"components"`
+	ID                  string              `json:"id,omitempty" yaml:"id,omitempty"`
+	Name                string              `json:"name" yaml:"name"`
+	RuleApplicabilities []RuleApplicability `json:"ruleApplicabilities,omitempty" yaml:"ruleApplicabilities,omitempty"`
+	MainComponents      string              `json:"mainComponents,omitempty" yaml:"mainComponents,omitempty"`
+	Components          []Component         `json:"components" yaml:"components"`
+	Groups              []ComponentGroup    `json:"groups,omitempty" yaml:"groups,omitempty"`
+	Bundles             []BundleDiscount    `json:"bundles,omitempty" yaml:"bundles,omitempty"`
 }
 
+// Condition is met when any of its values satisfies the rule.
 type Condition struct {
-	RuleName string `json:"ruleName"`
-	Value    string `json:"value"`
+	RuleName string   `json:"ruleName" yaml:"ruleName"`
+	Value    string   `json:"value" yaml:"value"`
+	Values   []string `json:"values,omitempty" yaml:"values,omitempty"`
+}
+
+// ComponentSelection narrows the optional components of an offer.
+// If Include is given, only the listed optional components are offered.
+// Main components are always required.
+type ComponentSelection struct {
+	Include []string `json:"include,omitempty" yaml:"include,omitempty"`
+	Exclude []string `json:"exclude,omitempty" yaml:"exclude,omitempty"`
+}
+
+// ExcludedComponent explains why a valid component is left out of the offer.
+type ExcludedComponent struct {
+	Name   string `json:"name" yaml:"name"`
+	Reason string `json:"reason" yaml:"reason"`
+}
+
+// AppliedBundle shows how much the bundle discount saved.
+type AppliedBundle struct {
+	Name  string  `json:"name" yaml:"name"`
+	Saved float64 `json:"saved" yaml:"saved"`
 }
 
+// Offer contains the selected components with their final costs.
+// AppliedBundles lists the bundle discounts already included in the costs.
+// Version is the catalog version of the product, it is empty for inline products.
+// An issued offer is stored as a quote valid until ExpiresAt and can be signed.
 type Offer struct {
-	Product
-	TotalCost Price `json:"totalCost"`
+	Product        `yaml:",inline"`
+	Version        int                 `json:"version,omitempty" yaml:"version,omitempty"`
+	QuoteID        string              `json:"quoteId,omitempty" yaml:"quoteId,omitempty"`
+	ExpiresAt      *time.Time          `json:"expiresAt,omitempty" yaml:"expiresAt,omitempty"`
+	TotalCost      Price               `json:"totalCost" yaml:"totalCost"`
+	Excluded       []ExcludedComponent `json:"excluded,omitempty" yaml:"excluded,omitempty"`
+	AppliedBundles []AppliedBundle     `json:"appliedBundles,omitempty" yaml:"appliedBundles,omitempty"`
+	Signature      string              `json:"signature,omitempty" yaml:"signature,omitempty"`
 }
@@ -17,6 +17,10 @@ const (
 	BadRequest
 	UnsupportedMediaType
 	MethodNotAllowed
+	NotFound
+	Conflict
+	PreconditionFailed
+	Gone
 )
 
 const (
@@ -0,0 +1,151 @@
+package sign
+
+// Package sign signs and verifies data with keys loaded from local files.
+
+import (
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+)
+
+const (
+	HMAC    = "hmac"
+	Ed25519 = "ed25519"
+)
+
+// Minimum length of an HMAC key in bytes.
+const minHMACKeySize = 32
+
+var (
+	// ErrInvalidSignature is returned when the signature does not match the data.
+	ErrInvalidSignature = errors.New("invalid signature")
+	// ErrVerifyOnly is returned when signing with a public key.
+	ErrVerifyOnly = errors.New("key can only verify signatures")
+)
+
+// Signer signs data and verifies signatures.
+type Signer interface {
+	Sign(data []byte) ([]byte, error)
+	Verify(data, signature []byte) error
+	Algorithm() string
+	// CanSign reports whether the key can sign or only verify.
+	CanSign() bool
+}
+
+// LoadKey reads the key file of the algorithm. An HMAC key file holds the raw secret,
+// an Ed25519 key file holds a PEM encoded PKCS #8 private key or PKIX public key.
+func LoadKey(algorithm, path string) (Signer, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch algorithm {
+	case HMAC:
+		return NewHMAC(data)
+	case Ed25519:
+		return ParseEd25519(data)
+	default:
+		return nil, fmt.Errorf("unknown algorithm: %s", algorithm)
+	}
+}
+
+type hmacSigner struct {
+	key []byte
+}
+
+// NewHMAC returns an HMAC-SHA256 signer with the secret key.
+func NewHMAC(key []byte) (Signer, error) {
+	if len(key) < minHMACKeySize {
+		return nil, fmt.Errorf("hmac key must have at least %d bytes", minHMACKeySize)
+	}
+	return &hmacSigner{key: key}, nil
+}
+
+func (s *hmacSigner) Sign(data []byte) ([]byte, error) {
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write(data)
+	return mac.Sum(nil), nil
+}
+
+func (s *hmacSigner) Verify(data, signature []byte) error {
+	expected, _ := s.Sign(data)
+	if !hmac.Equal(expected, signature) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+func (s *hmacSigner) Algorithm() string {
+	return HMAC
+}
+
+func (s *hmacSigner) CanSign() bool {
+	return true
+}
+
+type ed25519Signer struct {
+	private ed25519.PrivateKey
+	public  ed25519.PublicKey
+}
+
+// ParseEd25519 parses a PEM encoded Ed25519 private or public key.
+// A signer with a public key can only verify.
+func ParseEd25519(data []byte) (Signer, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+
+	switch block.Type {
+	case "PRIVATE KEY":
+		key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		private, ok := key.(ed25519.PrivateKey)
+		if !ok {
+			return nil, errors.New("not an ed25519 private key")
+		}
+		return &ed25519Signer{private: private, public: private.Public().(ed25519.PublicKey)}, nil
+	case "PUBLIC KEY":
+		key, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		public, ok := key.(ed25519.PublicKey)
+		if !ok {
+			return nil, errors.New("not an ed25519 public key")
+		}
+		return &ed25519Signer{public: public}, nil
+	default:
+		return nil, fmt.Errorf("unsupported PEM block: %s", block.Type)
+	}
+}
+
+func (s *ed25519Signer) Sign(data []byte) ([]byte, error) {
+	if s.private == nil {
+		return nil, ErrVerifyOnly
+	}
+	return ed25519.Sign(s.private, data), nil
+}
+
+func (s *ed25519Signer) Verify(data, signature []byte) error {
+	if !ed25519.Verify(s.public, data, signature) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+func (s *ed25519Signer) Algorithm() string {
+	return Ed25519
+}
+
+func (s *ed25519Signer) CanSign() bool {
+	return s.private != nil
+}
@@ -0,0 +1,69 @@
+package sign
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeKey(t *testing.T, data []byte) string {
+	dir, err := ioutil.TempDir("", "sign")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+
+	path := filepath.Join(dir, "key")
+	require.NoError(t, ioutil.WriteFile(path, data, 0600))
+	return path
+}
+
+func TestHMAC(t *testing.T) {
+	signer, err := LoadKey(HMAC, writeKey(t, []byte("0123456789abcdef0123456789abcdef")))
+	require.NoError(t, err)
+	assert.Equal(t, HMAC, signer.Algorithm())
+	assert.True(t, signer.CanSign())
+
+	signature, err := signer.Sign([]byte("offer"))
+	require.NoError(t, err)
+	assert.NoError(t, signer.Verify([]byte("offer"), signature))
+	assert.Equal(t, ErrInvalidSignature, signer.Verify([]byte("offer!"), signature))
+
+	_, err = LoadKey(HMAC, writeKey(t, []byte("short")))
+	assert.Error(t, err)
+}
+
+func TestEd25519(t *testing.T) {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	privateDER, err := x509.MarshalPKCS8PrivateKey(private)
+	require.NoError(t, err)
+	publicDER, err := x509.MarshalPKIXPublicKey(public)
+	require.NoError(t, err)
+
+	signer, err := LoadKey(Ed25519, writeKey(t, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privateDER})))
+	require.NoError(t, err)
+	verifier, err := LoadKey(Ed25519, writeKey(t, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDER})))
+	require.NoError(t, err)
+
+	signature, err := signer.Sign([]byte("offer"))
+	require.NoError(t, err)
+	assert.NoError(t, verifier.Verify([]byte("offer"), signature))
+	assert.Equal(t, ErrInvalidSignature, verifier.Verify([]byte("offer!"), signature))
+
+	_, err = verifier.Sign([]byte("offer"))
+	assert.Equal(t, ErrVerifyOnly, err)
+	assert.True(t, signer.CanSign())
+	assert.False(t, verifier.CanSign())
+
+	_, err = LoadKey(Ed25519, writeKey(t, []byte("not a key")))
+	assert.Error(t, err)
+	_, err = LoadKey("rsa", writeKey(t, []byte("key")))
+	assert.Error(t, err)
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+
+	"go-rti-testing/pkg/errors"
+)
+
+// Handler of the product collection.
+func (s *server) products(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodGet:
+		catalog := s.catalogs.Catalog()
+		products := make([]*Product, 0, len(catalog.IDs()))
+		for _, id := range catalog.IDs() {
+			stored, err := catalog.Get(id)
+			if err != nil {
+				httpError(w, err)
+				return
+			}
+			products = append(products, stored.Product)
+		}
+		if err := encodeBody(w, req, products); err != nil {
+			httpError(w, err)
+		}
+	case http.MethodPost:
+		product := new(Product)
+		if err := decodeBody(req, product); err != nil {
+			httpError(w, err)
+			return
+		}
+
+		stored, err := s.catalogs.Create(product)
+		if err != nil {
+			httpError(w, err)
+			return
+		}
+		w.Header().Set("Location", "/products/"+product.ID)
+		writeProduct(w, req, http.StatusCreated, stored)
+	default:
+		httpError(w, errors.MethodNotAllowed.New(""))
+	}
+}
+
+// Handler of a single product and its versions.
+func (s *server) productItem(w http.ResponseWriter, req *http.Request) {
+	id := strings.TrimPrefix(req.URL.Path, "/products/")
+	if strings.HasSuffix(id, "/versions") {
+		s.productVersions(w, req, strings.TrimSuffix(id, "/versions"))
+		return
+	}
+
+	switch req.Method {
+	case http.MethodGet:
+		stored, err := s.catalogs.Catalog().Get(id)
+		if err != nil {
+			httpError(w, err)
+			return
+		}
+		writeProduct(w, req, http.StatusOK, stored)
+	case http.MethodPut:
+		product := new(Product)
+		if err := decodeBody(req, product); err != nil {
+			httpError(w, err)
+			return
+		}
+		if product.ID != "" && product.ID != id {
+			httpError(w, errors.BadRequest.Newf("product id %s does not match %s", product.ID, id))
+			return
+		}
+		product.ID = id
+
+		stored, created, err := s.catalogs.Put(product, ifMatch(req))
+		if err != nil {
+			httpError(w, err)
+			return
+		}
+		status := http.StatusOK
+		if created {
+			status = http.StatusCreated
+		}
+		writeProduct(w, req, status, stored)
+	case http.MethodDelete:
+		if err := s.catalogs.Delete(id, ifMatch(req)); err != nil {
+			httpError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		httpError(w, errors.MethodNotAllowed.New(""))
+	}
+}
+
+func (s *server) productVersions(w http.ResponseWriter, req *http.Request, id string) {
+	if req.Method != http.MethodGet {
+		httpError(w, errors.MethodNotAllowed.New(""))
+		return
+	}
+
+	versions, err := s.catalogs.Versions(id)
+	if err != nil {
+		httpError(w, err)
+		return
+	}
+	if err := encodeBody(w, req, versions); err != nil {
+		httpError(w, err)
+	}
+}
+
+// Function writes the product with its entity tag.
+func writeProduct(w http.ResponseWriter, req *http.Request, status int, stored *StoredProduct) {
+	contentType, data, err := marshalBody(req, stored.Product)
+	if err != nil {
+		httpError(w, err)
+		return
+	}
+
+	w.Header().Set("ETag", `"`+stored.ETag+`"`)
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(status)
+	_, _ = w.Write(data)
+}
+
+// Function returns the entity tag of the If-Match header without quotes.
+func ifMatch(req *http.Request) string {
+	return strings.Trim(strings.TrimSpace(req.Header.Get("If-Match")), `"`)
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func serveProducts(handler http.Handler, method, path, body, ifMatch string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if ifMatch != "" {
+		req.Header.Set("If-Match", ifMatch)
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestProductsAPI(t *testing.T) {
+	dir := writeCatalog(t, map[string]interface{}{"gaming.json": product})
+	catalogs, err := NewCatalogStore(dir, NewMemoryStorage())
+	require.NoError(t, err)
+	handler := newServer(catalogs, nil).routes()
+
+	home := product
+	home.ID = "home"
+	home.Name = "Домашний"
+	body, err := json.Marshal(home)
+	require.NoError(t, err)
+
+	rec := serveProducts(handler, http.MethodPost, "/products", string(body), "")
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	assert.Equal(t, "/products/home", rec.Header().Get("Location"))
+	etag := rec.Header().Get("ETag")
+	assert.NotEmpty(t, etag)
+	assert.FileExists(t, filepath.Join(dir, "home.json"))
+
+	rec = serveProducts(handler, http.MethodPost, "/products", string(body), "")
+	assert.Equal(t, http.StatusConflict, rec.Code)
+
+	rec = serveProducts(handler, http.MethodGet, "/products", "", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	var products []Product
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &products))
+	require.Len(t, products, 2)
+	assert.Equal(t, "gaming", products[0].ID)
+	assert.Equal(t, "home", products[1].ID)
+
+	rec = serveProducts(handler, http.MethodGet, "/products/home", "", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, etag, rec.Header().Get("ETag"))
+
+	// Optimistic concurrency.
+	home.Name = "Домашний+"
+	body, err = json.Marshal(home)
+	require.NoError(t, err)
+	rec = serveProducts(handler, http.MethodPut, "/products/home", string(body), `"stale"`)
+	assert.Equal(t, http.StatusPreconditionFailed, rec.Code)
+	rec = serveProducts(handler, http.MethodPut, "/products/home", string(body), etag)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.NotEqual(t, etag, rec.Header().Get("ETag"))
+	rec = serveProducts(handler, http.MethodDelete, "/products/home", "", etag)
+	assert.Equal(t, http.StatusPreconditionFailed, rec.Code)
+
+	// Full validation on write.
+	rec = serveProducts(handler, http.MethodPut, "/products/broken", `{"name":"Сломанный","components":[{"name":"A","prices":[{"cost":-1}]}]}`, "")
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.NoFileExists(t, filepath.Join(dir, "broken.json"))
+	rec = serveProducts(handler, http.MethodPut, "/products/home", `{"id":"other","name":"Домашний"}`, "")
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	rec = serveProducts(handler, http.MethodPut, "/products/.hidden", string(body), "")
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = serveProducts(handler, http.MethodGet, "/products/home/versions", "", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	var versions []ProductVersion
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &versions))
+	assert.Len(t, versions, 2)
+
+	rec = serveProducts(handler, http.MethodDelete, "/products/home", "", "*")
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	_, err = os.Stat(filepath.Join(dir, "home.json"))
+	assert.True(t, os.IsNotExist(err))
+	rec = serveProducts(handler, http.MethodGet, "/products/home", "", "")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	rec = serveProducts(handler, http.MethodDelete, "/products/home", "", "")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"go-rti-testing/pkg/errors"
+	"go-rti-testing/pkg/sign"
+)
+
+// QuoteStore issues quotes for calculated offers and accepts them until they expire.
+// Offers of the quotes are signed if there is a signer.
+type QuoteStore struct {
+	storage Storage
+	ttl     time.Duration
+	signer  sign.Signer
+	now     func() time.Time
+	mu      sync.Mutex // serializes acceptance
+}
+
+func NewQuoteStore(storage Storage, ttl time.Duration, signer sign.Signer) *QuoteStore {
+	return &QuoteStore{storage: storage, ttl: ttl, signer: signer, now: time.Now}
+}
+
+// Issue stores the offer as a new quote and stamps the offer with the quote ID and expiry.
+func (s *QuoteStore) Issue(offer *Offer, conditions []Condition, selection *ComponentSelection) (*Quote, error) {
+	id, err := newQuoteID()
+	if err != nil {
+		return nil, errors.Internal.Wrap(err, "generate quote id")
+	}
+
+	now := s.now().UTC()
+	expiresAt := now.Add(s.ttl)
+	offer.QuoteID = id
+	offer.ExpiresAt = &expiresAt
+	if s.signer != nil {
+		if err := SignOffer(offer, s.signer); err != nil {
+			return nil, err
+		}
+	}
+
+	quote := &Quote{
+		ID:         id,
+		Conditions: conditions,
+		Selection:  selection,
+		Offer:      offer,
+		CreatedAt:  now,
+		ExpiresAt:  expiresAt,
+	}
+	if err := s.storage.PutQuote(quote); err != nil {
+		return nil, errors.Wrap(err, "store quote")
+	}
+	return quote, nil
+}
+
+// Get returns the quote by ID.
+func (s *QuoteStore) Get(id string) (*Quote, error) {
+	return s.storage.Quote(id)
+}
+
+// Verify checks the signature of the offer.
+func (s *QuoteStore) Verify(offer *Offer) error {
+	if s.signer == nil {
+		return errors.NotFound.New("quote signing is not configured")
+	}
+	return VerifyOffer(offer, s.signer)
+}
+
+// Accept marks the quote as accepted. An expired or already accepted quote is rejected.
+func (s *QuoteStore) Accept(id string) (*Quote, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	quote, err := s.storage.Quote(id)
+	if err != nil {
+		return nil, err
+	}
+	if quote.AcceptedAt != nil {
+		return nil, errors.Conflict.Newf("quote %s is already accepted", id)
+	}
+
+	now := s.now().UTC()
+	if !now.Before(quote.ExpiresAt) {
+		return nil, errors.Gone.Newf("quote %s expired at %s", id, quote.ExpiresAt.Format(time.RFC3339))
+	}
+
+	quote.AcceptedAt = &now
+	if err := s.storage.PutQuote(quote); err != nil {
+		return nil, errors.Wrap(err, "store quote")
+	}
+	return quote, nil
+}
+
+// Purge removes the expired quotes which were not accepted.
+func (s *QuoteStore) Purge() (int, error) {
+	return s.storage.PurgeQuotes(s.now().UTC())
+}
+
+// PurgeExpired purges the quotes at once and then every interval until the context is done.
+func (s *QuoteStore) PurgeExpired(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if purged, err := s.Purge(); err != nil {
+			log.Printf("ERROR purge quotes: %v", err)
+		} else if purged > 0 {
+			log.Printf("Purged %d expired quotes", purged)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func newQuoteID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// Handler of a quote and its acceptance.
+func (s *server) quote(w http.ResponseWriter, req *http.Request) {
+	id := strings.TrimPrefix(req.URL.Path, "/quotes/")
+	if id == "verify" {
+		s.verifyQuote(w, req)
+		return
+	}
+
+	var quote *Quote
+	var err error
+	switch {
+	case strings.HasSuffix(id, "/accept"):
+		if req.Method != http.MethodPost {
+			httpError(w, errors.MethodNotAllowed.New(""))
+			return
+		}
+		quote, err = s.quotes.Accept(strings.TrimSuffix(id, "/accept"))
+	case req.Method == http.MethodGet:
+		quote, err = s.quotes.Get(id)
+	default:
+		httpError(w, errors.MethodNotAllowed.New(""))
+		return
+	}
+	if err != nil {
+		httpError(w, err)
+		return
+	}
+
+	if err := encodeBody(w, req, quote); err != nil {
+		httpError(w, err)
+	}
+}
+
+// Handler checking the signature of an offer passed by a client.
+func (s *server) verifyQuote(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		httpError(w, errors.MethodNotAllowed.New(""))
+		return
+	}
+
+	var offer Offer
+	if err := decodeBody(req, &offer); err != nil {
+		httpError(w, err)
+		return
+	}
+
+	response := &VerifyResponse{Valid: true}
+	if err := s.quotes.Verify(&offer); err != nil {
+		if errors.GetType(err) != errors.BadRequest {
+			httpError(w, err)
+			return
+		}
+		response = &VerifyResponse{Error: err.Error()}
+	}
+
+	if err := encodeBody(w, req, response); err != nil {
+		httpError(w, err)
+	}
+}
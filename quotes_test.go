@@ -0,0 +1,84 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestQuotes(t *testing.T) {
+	catalogs, err := NewCatalogStore(writeCatalog(t, map[string]interface{}{"gaming.json": product}), NewMemoryStorage())
+	require.NoError(t, err)
+
+	now := time.Date(2021, 1, 1, 12, 0, 0, 0, time.UTC)
+	quotes := NewQuoteStore(NewMemoryStorage(), time.Hour, nil)
+	quotes.now = func() time.Time { return now }
+	handler := newServer(catalogs, quotes).routes()
+
+	serve := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	calculate := func() *Offer {
+		rec := serve(http.MethodPost, "/calculate",
+			`{"productId":"gaming","conditions":[{"ruleName":"technology","value":"adsl"},{"ruleName":"internetSpeed","value":"10"}]}`)
+		require.Equal(t, http.StatusOK, rec.Code)
+		var offer Offer
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &offer))
+		return &offer
+	}
+
+	offer := calculate()
+	require.NotEmpty(t, offer.QuoteID)
+	assert.Equal(t, now.Add(time.Hour), *offer.ExpiresAt)
+
+	rec := serve(http.MethodGet, "/quotes/"+offer.QuoteID, "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	var quote Quote
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &quote))
+	assert.Equal(t, offer.QuoteID, quote.ID)
+	assert.Equal(t, 1, quote.Offer.Version)
+	assert.Equal(t, offer.TotalCost, quote.Offer.TotalCost)
+	assert.Equal(t, []Condition{{RuleName: "technology", Value: "adsl"}, {RuleName: "internetSpeed", Value: "10"}}, quote.Conditions)
+
+	rec = serve(http.MethodPost, "/quotes/"+offer.QuoteID+"/accept", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &quote))
+	require.NotNil(t, quote.AcceptedAt)
+
+	rec = serve(http.MethodPost, "/quotes/"+offer.QuoteID+"/accept", "")
+	assert.Equal(t, http.StatusConflict, rec.Code)
+
+	expiring := calculate()
+	now = now.Add(time.Hour)
+	rec = serve(http.MethodPost, "/quotes/"+expiring.QuoteID+"/accept", "")
+	assert.Equal(t, http.StatusGone, rec.Code)
+
+	rec = serve(http.MethodGet, "/quotes/unknown", "")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	rec = serve(http.MethodGet, "/quotes/"+offer.QuoteID+"/accept", "")
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+
+	// YAML uses the JSON field names.
+	req := httptest.NewRequest(http.MethodGet, "/quotes/"+offer.QuoteID, nil)
+	req.Header.Set("Accept", "application/yaml")
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+	var fields map[string]interface{}
+	require.NoError(t, yaml.Unmarshal(rec.Body.Bytes(), &fields))
+	for _, name := range []string{"id", "conditions", "offer", "createdAt", "expiresAt", "acceptedAt"} {
+		assert.Contains(t, fields, name)
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"go-rti-testing/pkg/errors"
+)
+
+// Optional components chosen for a calculation. Members of groups
+// the user chose nothing from are offered by default.
+type selection struct {
+	include  map[string]bool
+	exclude  map[string]bool
+	defaults map[string]bool
+}
+
+// Error about the selected components. It fails the calculation
+// only when no condition set produces an offer.
+type selectionError struct {
+	error
+}
+
+// Function checks the selection against the product components.
+// Nil selection offers every valid component.
+func (p *CompiledProduct) newSelection(s *ComponentSelection) (*selection, error) {
+	if s == nil {
+		return nil, nil
+	}
+
+	components := make(map[string]*compiledComponent, len(p.components))
+	for i := range p.components {
+		components[p.components[i].name] = &p.components[i]
+	}
+
+	result := &selection{exclude: make(map[string]bool, len(s.Exclude))}
+	for _, name := range s.Exclude {
+		component, ok := components[name]
+		if !ok {
+			return nil, errors.BadRequest.Newf("unknown component: %s", name)
+		}
+		if component.isMain {
+			return nil, errors.BadRequest.Newf("main component %s cannot be excluded", name)
+		}
+		result.exclude[name] = true
+	}
+
+	if s.Include != nil {
+		result.include = make(map[string]bool, len(s.Include))
+	}
+	for _, name := range s.Include {
+		if _, ok := components[name]; !ok {
+			return nil, errors.BadRequest.Newf("unknown component: %s", name)
+		}
+		if result.exclude[name] {
+			return nil, errors.BadRequest.Newf("component %s is both included and excluded", name)
+		}
+		result.include[name] = true
+	}
+
+	if err := p.checkGroupChoice(result.include); err != nil {
+		return nil, err
+	}
+	if result.include != nil {
+		result.defaults = make(map[string]bool)
+		for _, group := range p.unchosenGroups(result.include) {
+			for _, name := range group.components {
+				result.defaults[name] = true
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// Function reports whether the component takes part in the offer.
+// Main components always do, even if the product needs only one of them.
+func (s *selection) offered(c *compiledComponent) bool {
+	if s == nil || c.isMain {
+		return true
+	}
+	if s.exclude[c.name] {
+		return false
+	}
+	return s.include == nil || s.include[c.name] || s.defaults[c.name]
+}
+
+// Function reports whether the component was explicitly included.
+func (s *selection) included(c *compiledComponent) bool {
+	return s != nil && s.include[c.name]
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+
+	"go-rti-testing/pkg/errors"
+)
+
+type server struct {
+	catalogs     *CatalogStore
+	quotes       *QuoteStore
+	batchWorkers int
+	batchMax     int
+}
+
+func newServer(catalogs *CatalogStore, quotes *QuoteStore) *server {
+	return &server{
+		catalogs:     catalogs,
+		quotes:       quotes,
+		batchWorkers: defaultBatchWorkers,
+		batchMax:     defaultBatchMax,
+	}
+}
+
+func (s *server) routes() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ping", ping)
+	mux.HandleFunc("/calculate", s.calculate)
+	mux.HandleFunc("/calculate/best", s.best)
+	mux.HandleFunc("/calculate/batch", s.batch)
+	mux.HandleFunc("/calculate/stream", s.stream)
+	mux.HandleFunc("/analyze", analyze)
+	mux.HandleFunc("/lookup", lookup)
+	mux.HandleFunc("/enumerate", enumerate)
+	if s.catalogs != nil {
+		mux.HandleFunc("/products", s.products)
+		mux.HandleFunc("/products/", s.productItem)
+	}
+	if s.quotes != nil {
+		mux.HandleFunc("/quotes/", s.quote)
+	}
+	return mux
+}
+
+// Function returns the inline product or the requested version of the catalog product.
+func (s *server) product(req *CalculateRequest) (*CompiledProduct, error) {
+	switch {
+	case req.Product != nil && req.ProductID != "":
+		return nil, errors.BadRequest.New("product and productId are mutually exclusive")
+	case req.ProductID == "" && (req.Version != 0 || req.AsOf != nil):
+		return nil, errors.BadRequest.New("version and asOf require productId")
+	case req.Version != 0 && req.AsOf != nil:
+		return nil, errors.BadRequest.New("version and asOf are mutually exclusive")
+	case req.Product != nil:
+		return Compile(req.Product), nil
+	case req.Version != 0:
+		return s.catalogs.Version(req.ProductID, req.Version)
+	case req.AsOf != nil:
+		return s.catalogs.AsOf(req.ProductID, *req.AsOf)
+	case req.ProductID != "":
+		return s.catalogs.Catalog().Product(req.ProductID)
+	default:
+		return nil, errors.BadRequest.New("product or productId is required")
+	}
+}
+
+// Function calculates the offer of the request and issues a quote for it if asked.
+// Offers of inline products are made up by the client, so they are never quoted.
+// If there is no offer, then returns the reason.
+func (s *server) calculateRequest(calcReq *CalculateRequest, quote bool) (*Offer, string, error) {
+	product, err := s.product(calcReq)
+	if err != nil {
+		return nil, "", err
+	}
+
+	offer, reason, err := product.calculate(calcReq.Conditions, calcReq.Selection)
+	if err != nil || offer == nil {
+		return nil, reason, err
+	}
+
+	if quote && s.quotes != nil && calcReq.ProductID != "" {
+		if _, err := s.quotes.Issue(offer, calcReq.Conditions, calcReq.Selection); err != nil {
+			return nil, "", err
+		}
+	}
+	return offer, "", nil
+}
+
+func ping(w http.ResponseWriter, _ *http.Request) {
+	_, _ = fmt.Fprint(w, "pong")
+}
+
+func (s *server) calculate(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		httpError(w, errors.MethodNotAllowed.New(""))
+		return
+	}
+
+	var calcReq CalculateRequest
+	if err := decodeBody(req, &calcReq); err != nil {
+		httpError(w, err)
+		return
+	}
+
+	offer, _, err := s.calculateRequest(&calcReq, true)
+	if err != nil {
+		httpError(w, err)
+		return
+	}
+
+	if offer != nil {
+		if err := encodeBody(w, req, offer); err != nil {
+			httpError(w, err)
+			return
+		}
+	}
+}
+
+func (s *server) best(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		httpError(w, errors.MethodNotAllowed.New(""))
+		return
+	}
+
+	var bestReq BestRequest
+	if err := decodeJson(req, &bestReq); err != nil {
+		httpError(w, err)
+		return
+	}
+
+	products := make([]*CompiledProduct, 0, len(bestReq.Products)+len(bestReq.ProductIDs))
+	for i := range bestReq.Products {
+		products = append(products, Compile(&bestReq.Products[i]))
+	}
+	catalog := s.catalogs.Catalog()
+	for _, id := range bestReq.ProductIDs {
+		product, err := catalog.Product(id)
+		if err != nil {
+			httpError(w, err)
+			return
+		}
+		products = append(products, product)
+	}
+
+	if err := encodeJson(w, Best(products, bestReq.Conditions)); err != nil {
+		httpError(w, err)
+		return
+	}
+}
+
+func analyze(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		httpError(w, errors.MethodNotAllowed.New(""))
+		return
+	}
+
+	var analyzeReq AnalyzeRequest
+	if err := decodeJson(req, &analyzeReq); err != nil {
+		httpError(w, err)
+		return
+	}
+
+	if err := encodeJson(w, Analyze(&analyzeReq.Product)); err != nil {
+		httpError(w, err)
+		return
+	}
+}
+
+func lookup(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		httpError(w, errors.MethodNotAllowed.New(""))
+		return
+	}
+
+	var lookupReq LookupRequest
+	if err := decodeJson(req, &lookupReq); err != nil {
+		httpError(w, err)
+		return
+	}
+
+	result, err := Lookup(&lookupReq.Product, lookupReq.Component, lookupReq.Cost)
+	if err != nil {
+		httpError(w, err)
+		return
+	}
+
+	if err := encodeJson(w, result); err != nil {
+		httpError(w, err)
+		return
+	}
+}
+
+func enumerate(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		httpError(w, errors.MethodNotAllowed.New(""))
+		return
+	}
+
+	var enumerateReq EnumerateRequest
+	if err := decodeJson(req, &enumerateReq); err != nil {
+		httpError(w, err)
+		return
+	}
+
+	result, err := Enumerate(&enumerateReq.Product, enumerateReq.Domains)
+	if err != nil {
+		httpError(w, err)
+		return
+	}
+
+	if err := encodeJson(w, result); err != nil {
+		httpError(w, err)
+		return
+	}
+}
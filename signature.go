@@ -0,0 +1,62 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+
+	"go-rti-testing/pkg/errors"
+	"go-rti-testing/pkg/sign"
+)
+
+// VerifyResponse is the result of a signature check.
+type VerifyResponse struct {
+	Valid bool   `json:"valid" yaml:"valid"`
+	Error string `json:"error,omitempty" yaml:"error,omitempty"`
+}
+
+// SignOffer signs the JSON of the offer without the signature.
+func SignOffer(offer *Offer, signer sign.Signer) error {
+	payload, err := offerPayload(offer)
+	if err != nil {
+		return err
+	}
+
+	signature, err := signer.Sign(payload)
+	if err != nil {
+		return errors.Internal.Wrap(err, "sign offer")
+	}
+	offer.Signature = base64.StdEncoding.EncodeToString(signature)
+	return nil
+}
+
+// VerifyOffer checks the signature of the offer. Any change of the signed offer,
+// such as a total cost or a component list, makes the signature invalid.
+func VerifyOffer(offer *Offer, verifier sign.Signer) error {
+	if offer.Signature == "" {
+		return errors.BadRequest.New("offer is not signed")
+	}
+	signature, err := base64.StdEncoding.DecodeString(offer.Signature)
+	if err != nil {
+		return errors.BadRequest.New("invalid signature encoding")
+	}
+
+	payload, err := offerPayload(offer)
+	if err != nil {
+		return err
+	}
+	if err := verifier.Verify(payload, signature); err != nil {
+		return errors.BadRequest.Wrap(err, "offer")
+	}
+	return nil
+}
+
+// Function returns the signed representation of the offer.
+func offerPayload(offer *Offer) ([]byte, error) {
+	unsigned := *offer
+	unsigned.Signature = ""
+	payload, err := json.Marshal(&unsigned)
+	if err != nil {
+		return nil, errors.Internal.Wrap(err, "encode offer")
+	}
+	return payload, nil
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go-rti-testing/pkg/sign"
+)
+
+func TestSignedQuotes(t *testing.T) {
+	signer, err := sign.NewHMAC([]byte("0123456789abcdef0123456789abcdef"))
+	require.NoError(t, err)
+	catalogs, err := NewCatalogStore(writeCatalog(t, map[string]interface{}{"gaming.json": product}), NewMemoryStorage())
+	require.NoError(t, err)
+	handler := newServer(catalogs, NewQuoteStore(NewMemoryStorage(), time.Hour, signer)).routes()
+
+	body, err := json.Marshal(CalculateRequest{
+		ProductID:  "gaming",
+		Conditions: []Condition{{RuleName: "technology", Value: "adsl"}, {RuleName: "internetSpeed", Value: "10"}},
+	})
+	require.NoError(t, err)
+	req := httptest.NewRequest(http.MethodPost, "/calculate", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var offer Offer
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &offer))
+	require.NotEmpty(t, offer.Signature)
+	assert.NoError(t, VerifyOffer(&offer, signer))
+
+	verify := func(offer Offer) VerifyResponse {
+		body, err := json.Marshal(offer)
+		require.NoError(t, err)
+		req := httptest.NewRequest(http.MethodPost, "/quotes/verify", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+		var response VerifyResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+		return response
+	}
+
+	assert.Equal(t, VerifyResponse{Valid: true}, verify(offer))
+
+	cheaper := offer
+	cheaper.TotalCost.Cost = 1
+	assert.Equal(t, VerifyResponse{Error: "offer: invalid signature"}, verify(cheaper))
+
+	fewer := offer
+	fewer.Components = offer.Components[:1]
+	assert.False(t, verify(fewer).Valid)
+
+	unsigned := offer
+	unsigned.Signature = ""
+	assert.Equal(t, VerifyResponse{Error: "offer is not signed"}, verify(unsigned))
+}
+
+func TestInlineOfferNotQuoted(t *testing.T) {
+	signer, err := sign.NewHMAC([]byte("0123456789abcdef0123456789abcdef"))
+	require.NoError(t, err)
+	storage := NewMemoryStorage()
+	handler := newServer(nil, NewQuoteStore(storage, time.Hour, signer)).routes()
+
+	cheap := product
+	cheap.Components = []Component{{Name: "Интернет", IsMain: true, Prices: []Price{{Cost: 1, PriceType: PriceTypeCost}}}}
+	body, err := json.Marshal(CalculateRequest{Product: &cheap, Conditions: []Condition{}})
+	require.NoError(t, err)
+	req := httptest.NewRequest(http.MethodPost, "/calculate", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	var offer Offer
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &offer))
+	assert.Equal(t, 1.0, offer.TotalCost.Cost)
+	assert.Empty(t, offer.QuoteID)
+	assert.Nil(t, offer.ExpiresAt)
+	assert.Empty(t, offer.Signature)
+	assert.Empty(t, storage.quotes)
+}
+
+func TestVerifyWithoutSigner(t *testing.T) {
+	handler := newServer(nil, NewQuoteStore(NewMemoryStorage(), time.Hour, nil)).routes()
+	req := httptest.NewRequest(http.MethodPost, "/quotes/verify", strings.NewReader(`{"name":"Игровой"}`))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
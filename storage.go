@@ -0,0 +1,127 @@
+package main
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"go-rti-testing/pkg/errors"
+)
+
+// Storage persists catalog product versions and issued quotes.
+// Implementations must be safe for concurrent use.
+type Storage interface {
+	// AppendVersion stores a new product version.
+	AppendVersion(version ProductVersion) error
+	// Versions returns all stored product versions in the order they were appended.
+	Versions() ([]ProductVersion, error)
+	// PutQuote stores the quote, replacing a quote with the same ID.
+	PutQuote(quote *Quote) error
+	// Quote returns the quote by ID.
+	Quote(id string) (*Quote, error)
+	// PurgeQuotes removes the quotes which were not accepted and expired by the time,
+	// and returns their number.
+	PurgeQuotes(now time.Time) (int, error)
+	Close() error
+}
+
+// Quote is an offer issued for the conditions.
+type Quote struct {
+	ID         string              `json:"id" yaml:"id"`
+	Conditions []Condition         `json:"conditions" yaml:"conditions"`
+	Selection  *ComponentSelection `json:"components,omitempty" yaml:"components,omitempty"`
+	Offer      *Offer              `json:"offer" yaml:"offer"`
+	CreatedAt  time.Time           `json:"createdAt" yaml:"createdAt"`
+	ExpiresAt  time.Time           `json:"expiresAt" yaml:"expiresAt"`
+	AcceptedAt *time.Time          `json:"acceptedAt,omitempty" yaml:"acceptedAt,omitempty"`
+}
+
+// Function reports whether the quote was not accepted and expired by the time.
+func (q *Quote) expired(now time.Time) bool {
+	return q.AcceptedAt == nil && !now.Before(q.ExpiresAt)
+}
+
+// Function opens the file storage or the memory storage if there is no path.
+func openStorage(path string) (Storage, error) {
+	if path == "" {
+		return NewMemoryStorage(), nil
+	}
+	return OpenFileStorage(path)
+}
+
+// MemoryStorage keeps everything in memory, it is meant for tests and development.
+type MemoryStorage struct {
+	mu       sync.RWMutex
+	versions []ProductVersion
+	quotes   map[string]*Quote
+}
+
+func NewMemoryStorage() *MemoryStorage {
+	return &MemoryStorage{quotes: make(map[string]*Quote)}
+}
+
+func (s *MemoryStorage) AppendVersion(version ProductVersion) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.versions = append(s.versions, version)
+	return nil
+}
+
+func (s *MemoryStorage) Versions() ([]ProductVersion, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return append([]ProductVersion(nil), s.versions...), nil
+}
+
+func (s *MemoryStorage) PutQuote(quote *Quote) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	copied := *quote
+	s.quotes[quote.ID] = &copied
+	return nil
+}
+
+func (s *MemoryStorage) Quote(id string) (*Quote, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	quote, ok := s.quotes[id]
+	if !ok {
+		return nil, errors.NotFound.Newf("unknown quote: %s", id)
+	}
+	copied := *quote
+	return &copied, nil
+}
+
+func (s *MemoryStorage) PurgeQuotes(now time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	purged := 0
+	for id, quote := range s.quotes {
+		if quote.expired(now) {
+			delete(s.quotes, id)
+			purged++
+		}
+	}
+	return purged, nil
+}
+
+// Function returns the stored quotes ordered by creation.
+func (s *MemoryStorage) allQuotes() []*Quote {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	quotes := make([]*Quote, 0, len(s.quotes))
+	for _, quote := range s.quotes {
+		quotes = append(quotes, quote)
+	}
+	sort.Slice(quotes, func(i, j int) bool {
+		if !quotes[i].CreatedAt.Equal(quotes[j].CreatedAt) {
+			return quotes[i].CreatedAt.Before(quotes[j].CreatedAt)
+		}
+		return quotes[i].ID < quotes[j].ID
+	})
+	return quotes
+}
+
+func (s *MemoryStorage) Close() error {
+	return nil
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"go-rti-testing/pkg/errors"
+)
+
+// FileStorage is an append-only log of JSON lines in a local file.
+// The log is replayed into memory on open, every write is synced to disk.
+// Purging quotes rewrites the log with the remaining records only.
+type FileStorage struct {
+	mu       sync.Mutex // serializes writes, so the log matches the memory
+	path     string
+	file     *os.File
+	memory   *MemoryStorage
+	readOnly bool
+}
+
+// Record of the storage log, exactly one of the fields is set.
+type storageRecord struct {
+	Version *ProductVersion `json:"version,omitempty"`
+	Quote   *Quote          `json:"quote,omitempty"`
+}
+
+// OpenFileStorage opens or creates the storage file and replays it.
+// A partially written last record left by a crash is discarded.
+func OpenFileStorage(path string) (*FileStorage, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, errors.Wrapf(err, "open storage %s", path)
+	}
+
+	s := &FileStorage{path: path, file: file, memory: NewMemoryStorage()}
+	if err := s.replay(); err != nil {
+		_ = file.Close()
+		return nil, errors.Wrapf(err, "replay storage %s", path)
+	}
+	return s, nil
+}
+
+// OpenFileStorageReadOnly replays the storage file without changing it,
+// so it can be read while a server writes to it. A partially written
+// last record is skipped. Writes fail.
+func OpenFileStorageReadOnly(path string) (*FileStorage, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "open storage %s", path)
+	}
+
+	s := &FileStorage{path: path, file: file, memory: NewMemoryStorage(), readOnly: true}
+	if err := s.replay(); err != nil {
+		_ = file.Close()
+		return nil, errors.Wrapf(err, "replay storage %s", path)
+	}
+	return s, nil
+}
+
+// Function reads all records and leaves the file positioned at the end of the last complete one.
+func (s *FileStorage) replay() error {
+	reader := bufio.NewReader(s.file)
+	var offset int64
+	for line := 1; ; line++ {
+		data, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			// Partial record without the line end.
+			if s.readOnly {
+				return nil
+			}
+			if err := s.file.Truncate(offset); err != nil {
+				return err
+			}
+			_, err = s.file.Seek(offset, io.SeekStart)
+			return err
+		}
+		if err != nil {
+			return err
+		}
+
+		offset += int64(len(data))
+		if len(bytes.TrimSpace(data)) == 0 {
+			continue
+		}
+
+		var record storageRecord
+		if err := json.Unmarshal(data, &record); err != nil {
+			return errors.Wrapf(err, "line %d", line)
+		}
+		switch {
+		case record.Version != nil:
+			err = s.memory.AppendVersion(*record.Version)
+		case record.Quote != nil:
+			err = s.memory.PutQuote(record.Quote)
+		default:
+			err = errors.Internal.Newf("line %d: empty record", line)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (s *FileStorage) AppendVersion(version ProductVersion) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.append(storageRecord{Version: &version}); err != nil {
+		return err
+	}
+	return s.memory.AppendVersion(version)
+}
+
+func (s *FileStorage) Versions() ([]ProductVersion, error) {
+	return s.memory.Versions()
+}
+
+func (s *FileStorage) PutQuote(quote *Quote) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.append(storageRecord{Quote: quote}); err != nil {
+		return err
+	}
+	return s.memory.PutQuote(quote)
+}
+
+func (s *FileStorage) Quote(id string) (*Quote, error) {
+	return s.memory.Quote(id)
+}
+
+func (s *FileStorage) PurgeQuotes(now time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.readOnly {
+		return 0, errors.Internal.Newf("storage %s is read-only", s.path)
+	}
+
+	// The log is compacted before the memory is changed,
+	// so a failed compaction leaves both as they were.
+	expired := 0
+	for _, quote := range s.memory.allQuotes() {
+		if quote.expired(now) {
+			expired++
+		}
+	}
+	if expired == 0 {
+		return 0, nil
+	}
+	if err := s.compact(func(quote *Quote) bool { return !quote.expired(now) }); err != nil {
+		return 0, err
+	}
+	return s.memory.PurgeQuotes(now)
+}
+
+func (s *FileStorage) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.file.Close()
+}
+
+// Function writes the record as a single line and syncs the file. The caller holds mu.
+func (s *FileStorage) append(record storageRecord) error {
+	if s.readOnly {
+		return errors.Internal.Newf("storage %s is read-only", s.path)
+	}
+
+	data, err := json.Marshal(record)
+	if err != nil {
+		return errors.Internal.Wrap(err, "encode storage record")
+	}
+
+	if _, err := s.file.Write(append(data, '\n')); err != nil {
+		return errors.Internal.Wrap(err, "write storage record")
+	}
+	if err := s.file.Sync(); err != nil {
+		return errors.Internal.Wrap(err, "sync storage")
+	}
+	return nil
+}
+
+// Function rewrites the log with a record per version and per kept quote in memory
+// and replaces the file with it. The caller holds mu.
+func (s *FileStorage) compact(keep func(quote *Quote) bool) error {
+	versions, err := s.memory.Versions()
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.OpenFile(s.path+".tmp", os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return errors.Internal.Wrap(err, "create compacted storage")
+	}
+	writer := bufio.NewWriter(tmp)
+	encoder := json.NewEncoder(writer)
+	for i := range versions {
+		if err = encoder.Encode(storageRecord{Version: &versions[i]}); err != nil {
+			break
+		}
+	}
+	if err == nil {
+		for _, quote := range s.memory.allQuotes() {
+			if !keep(quote) {
+				continue
+			}
+			if err = encoder.Encode(storageRecord{Quote: quote}); err != nil {
+				break
+			}
+		}
+	}
+	if err == nil {
+		err = writer.Flush()
+	}
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), s.path)
+	}
+	if err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return errors.Internal.Wrap(err, "compact storage")
+	}
+
+	_ = s.file.Close()
+	s.file = tmp
+	return nil
+}
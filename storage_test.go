@@ -0,0 +1,185 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go-rti-testing/pkg/errors"
+)
+
+func TestStorage(t *testing.T) {
+	path := filepath.Join(writeCatalog(t, nil), "data.jsonl")
+	file, err := OpenFileStorage(path)
+	require.NoError(t, err)
+	defer file.Close()
+
+	for name, storage := range map[string]Storage{"memory": NewMemoryStorage(), "file": file} {
+		t.Run(name, func(t *testing.T) {
+			require.NoError(t, storage.AppendVersion(ProductVersion{ProductID: "gaming", Version: 1, Product: &product}))
+			require.NoError(t, storage.AppendVersion(ProductVersion{ProductID: "gaming", Version: 2}))
+
+			versions, err := storage.Versions()
+			require.NoError(t, err)
+			require.Len(t, versions, 2)
+			assert.Equal(t, product.Name, versions[0].Product.Name)
+			assert.Nil(t, versions[1].Product)
+
+			quote := &Quote{ID: "q1", Offer: &Offer{TotalCost: Price{Cost: 400}}}
+			require.NoError(t, storage.PutQuote(quote))
+			quote.Offer = &Offer{TotalCost: Price{Cost: 500}}
+			require.NoError(t, storage.PutQuote(quote))
+
+			stored, err := storage.Quote("q1")
+			require.NoError(t, err)
+			assert.Equal(t, 500.0, stored.Offer.TotalCost.Cost)
+
+			_, err = storage.Quote("q2")
+			assert.Equal(t, errors.NotFound, errors.GetType(err))
+		})
+	}
+}
+
+func TestFileStorageReplay(t *testing.T) {
+	path := filepath.Join(writeCatalog(t, nil), "data.jsonl")
+	storage, err := OpenFileStorage(path)
+	require.NoError(t, err)
+	require.NoError(t, storage.AppendVersion(ProductVersion{ProductID: "gaming", Version: 1, Product: &product}))
+	require.NoError(t, storage.PutQuote(&Quote{ID: "q1", CreatedAt: time.Now()}))
+	require.NoError(t, storage.Close())
+
+	// A record cut by a crash is discarded.
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	require.NoError(t, err)
+	_, err = f.WriteString(`{"quote":{"id":"q2"`)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	storage, err = OpenFileStorage(path)
+	require.NoError(t, err)
+	versions, err := storage.Versions()
+	require.NoError(t, err)
+	assert.Len(t, versions, 1)
+	_, err = storage.Quote("q1")
+	assert.NoError(t, err)
+	_, err = storage.Quote("q2")
+	assert.Error(t, err)
+
+	require.NoError(t, storage.PutQuote(&Quote{ID: "q3"}))
+	require.NoError(t, storage.Close())
+	data, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "q2")
+	assert.Contains(t, string(data), "q3")
+}
+
+func TestCatalogHistorySurvivesRestart(t *testing.T) {
+	dir := writeCatalog(t, map[string]interface{}{"gaming.json": product})
+	path := filepath.Join(writeCatalog(t, nil), "data.jsonl")
+
+	storage, err := OpenFileStorage(path)
+	require.NoError(t, err)
+	_, err = NewCatalogStore(dir, storage)
+	require.NoError(t, err)
+	require.NoError(t, storage.Close())
+
+	changed := product
+	changed.Name = "Игровой+"
+	writeCatalogFile(t, dir, "gaming.json", changed)
+
+	storage, err = OpenFileStorage(path)
+	require.NoError(t, err)
+	defer storage.Close()
+	catalogs, err := NewCatalogStore(dir, storage)
+	require.NoError(t, err)
+
+	versions, err := catalogs.Versions("gaming")
+	require.NoError(t, err)
+	require.Len(t, versions, 2)
+	assert.Equal(t, "Игровой", versions[0].Product.Name)
+
+	compiled, err := catalogs.Version("gaming", 1)
+	require.NoError(t, err)
+	offer, err := compiled.Calculate([]Condition{{RuleName: "technology", Value: "adsl"}, {RuleName: "internetSpeed", Value: "10"}})
+	require.NoError(t, err)
+	assert.Equal(t, 1, offer.Version)
+	assert.Equal(t, "Игровой", offer.Name)
+}
+
+func TestPurgeQuotes(t *testing.T) {
+	path := filepath.Join(writeCatalog(t, nil), "data.jsonl")
+	file, err := OpenFileStorage(path)
+	require.NoError(t, err)
+	defer file.Close()
+
+	now := time.Date(2021, 1, 1, 12, 0, 0, 0, time.UTC)
+	accepted := now.Add(-2 * time.Hour)
+	for name, storage := range map[string]Storage{"memory": NewMemoryStorage(), "file": file} {
+		t.Run(name, func(t *testing.T) {
+			require.NoError(t, storage.AppendVersion(ProductVersion{ProductID: "gaming", Version: 1, Product: &product}))
+			require.NoError(t, storage.PutQuote(&Quote{ID: "expired", ExpiresAt: now.Add(-time.Hour)}))
+			require.NoError(t, storage.PutQuote(&Quote{ID: "accepted", ExpiresAt: now.Add(-time.Hour), AcceptedAt: &accepted}))
+			require.NoError(t, storage.PutQuote(&Quote{ID: "active", ExpiresAt: now.Add(time.Hour)}))
+
+			purged, err := storage.PurgeQuotes(now)
+			require.NoError(t, err)
+			assert.Equal(t, 1, purged)
+
+			_, err = storage.Quote("expired")
+			assert.Equal(t, errors.NotFound, errors.GetType(err))
+			for _, id := range []string{"accepted", "active"} {
+				_, err = storage.Quote(id)
+				assert.NoError(t, err, id)
+			}
+
+			purged, err = storage.PurgeQuotes(now)
+			require.NoError(t, err)
+			assert.Equal(t, 0, purged)
+		})
+	}
+
+	// The compacted log keeps the versions and the remaining quotes and takes new records.
+	require.NoError(t, file.PutQuote(&Quote{ID: "new", ExpiresAt: now.Add(time.Hour)}))
+	require.NoError(t, file.Close())
+	data, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), `"expired"`)
+
+	file, err = OpenFileStorage(path)
+	require.NoError(t, err)
+	defer file.Close()
+	versions, err := file.Versions()
+	require.NoError(t, err)
+	assert.Len(t, versions, 1)
+	for _, id := range []string{"accepted", "active", "new"} {
+		_, err = file.Quote(id)
+		assert.NoError(t, err, id)
+	}
+}
+
+func TestPurgeQuotesCompactionFailure(t *testing.T) {
+	path := filepath.Join(writeCatalog(t, nil), "data.jsonl")
+	file, err := OpenFileStorage(path)
+	require.NoError(t, err)
+	defer file.Close()
+
+	now := time.Date(2021, 1, 1, 12, 0, 0, 0, time.UTC)
+	require.NoError(t, file.PutQuote(&Quote{ID: "expired", ExpiresAt: now.Add(-time.Hour)}))
+
+	// The temporary file of the compaction cannot be created.
+	require.NoError(t, os.Mkdir(path+".tmp", 0755))
+	_, err = file.PurgeQuotes(now)
+	assert.Equal(t, errors.Internal, errors.GetType(err))
+	_, err = file.Quote("expired")
+	assert.NoError(t, err)
+
+	require.NoError(t, os.Remove(path+".tmp"))
+	purged, err := file.PurgeQuotes(now)
+	require.NoError(t, err)
+	assert.Equal(t, 1, purged)
+}
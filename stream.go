@@ -0,0 +1,157 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"net/http/httputil"
+	"strings"
+
+	"go-rti-testing/pkg/errors"
+)
+
+// Maximum length of one NDJSON request line.
+const maxStreamLine = 1 << 20
+
+// Handler of NDJSON streams: every line of the body is a CalculateRequest
+// and every result is written as a line as soon as it is ready.
+// Results keep the order of the requests.
+func (s *server) stream(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		httpError(w, errors.MethodNotAllowed.New(""))
+		return
+	}
+	if mediaType, _, _ := mime.ParseMediaType(req.Header.Get("Content-Type")); mediaType != "application/x-ndjson" {
+		httpError(w, errors.UnsupportedMediaType.New("application/x-ndjson"))
+		return
+	}
+
+	// The HTTP/1.x server stops reading the request body once the response
+	// is started, so the connection is taken over to read and write at the same time.
+	if hijacker, ok := w.(http.Hijacker); ok && req.ProtoMajor == 1 {
+		s.streamHijacked(hijacker, req)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	flusher, _ := w.(http.Flusher)
+	writeStream(req.Body, w, s, func() error {
+		if flusher != nil {
+			flusher.Flush()
+		}
+		return nil
+	})
+}
+
+// Function serves the HTTP/1.x stream on the hijacked connection
+// with a chunked response which is closed at the end.
+func (s *server) streamHijacked(hijacker http.Hijacker, req *http.Request) {
+	conn, rw, err := hijacker.Hijack()
+	if err != nil {
+		log.Printf("ERROR hijack connection: %v", err)
+		return
+	}
+	defer conn.Close()
+
+	var body io.Reader
+	switch {
+	case len(req.TransferEncoding) > 0 && req.TransferEncoding[0] == "chunked":
+		body = httputil.NewChunkedReader(rw.Reader)
+	case req.ContentLength > 0:
+		body = io.LimitReader(rw.Reader, req.ContentLength)
+	default:
+		body = strings.NewReader("")
+	}
+
+	if strings.EqualFold(req.Header.Get("Expect"), "100-continue") {
+		_, _ = fmt.Fprintf(rw, "HTTP/1.1 100 Continue\r\n\r\n")
+	}
+	_, _ = fmt.Fprintf(rw, "HTTP/1.1 200 OK\r\nContent-Type: application/x-ndjson\r\nTransfer-Encoding: chunked\r\nConnection: close\r\n\r\n")
+	if err := rw.Flush(); err != nil {
+		return
+	}
+
+	chunked := httputil.NewChunkedWriter(rw)
+	writeStream(body, chunked, s, rw.Flush)
+	if err := chunked.Close(); err != nil {
+		return
+	}
+	_, _ = io.WriteString(rw, "\r\n")
+	_ = rw.Flush()
+}
+
+// Function writes the results of the NDJSON requests and flushes after each line.
+// A failure to read the requests is reported by a last line with the error.
+func writeStream(body io.Reader, w io.Writer, s *server, flush func() error) {
+	encoder := json.NewEncoder(w)
+	err := s.calculateStream(body, func(result *BatchResult) error {
+		if err := encoder.Encode(result); err != nil {
+			return err
+		}
+		return flush()
+	})
+	if err != nil && errors.GetType(err) == errors.BadRequest {
+		status, message := errorStatus(err)
+		_ = encoder.Encode(&BatchResult{Error: &BatchError{Status: status, Message: message}})
+		_ = flush()
+	}
+}
+
+// Function calculates the NDJSON requests of the reader concurrently and passes
+// the results to write in the order of the requests. At most batchWorkers requests
+// are read ahead of the written results. A line which is not a request gets an error result.
+// Streams are meant for bulk repricing, so their offers are not quoted.
+func (s *server) calculateStream(r io.Reader, write func(result *BatchResult) error) error {
+	queue := make(chan chan BatchResult, s.batchWorkers)
+	done := make(chan struct{})
+	defer close(done)
+
+	var readErr error
+	go func() {
+		defer close(queue)
+
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(make([]byte, 0, 64*1024), maxStreamLine)
+		for line := 1; scanner.Scan(); line++ {
+			data := bytes.TrimSpace(scanner.Bytes())
+			if len(data) == 0 {
+				continue
+			}
+
+			result := make(chan BatchResult, 1)
+			select {
+			case queue <- result:
+			case <-done:
+				return
+			}
+
+			var calcReq CalculateRequest
+			if err := json.Unmarshal(data, &calcReq); err != nil {
+				result <- BatchResult{Error: &BatchError{
+					Status:  http.StatusBadRequest,
+					Message: fmt.Sprintf("line %d: invalid request", line),
+				}}
+				continue
+			}
+			go func() {
+				result <- s.calculateBatchItem(&calcReq, false)
+			}()
+		}
+		if err := scanner.Err(); err != nil {
+			readErr = errors.BadRequest.Wrap(err, "read requests")
+		}
+	}()
+
+	for result := range queue {
+		r := <-result
+		if err := write(&r); err != nil {
+			return err
+		}
+	}
+	return readErr
+}
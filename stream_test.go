@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const streamLine = `{"id":"%s","productId":"gaming","conditions":[{"ruleName":"technology","value":"adsl"},{"ruleName":"internetSpeed","value":"10"}]}`
+
+func TestCalculateStream(t *testing.T) {
+	catalogs, err := NewCatalogStore(writeCatalog(t, map[string]interface{}{"gaming.json": product}), NewMemoryStorage())
+	require.NoError(t, err)
+	storage := NewMemoryStorage()
+	srv := newServer(catalogs, NewQuoteStore(storage, time.Hour, nil))
+	srv.batchWorkers = 2
+	handler := srv.routes()
+
+	body := strings.Join([]string{
+		fmt.Sprintf(streamLine, "adsl"),
+		"",
+		`{"id":"unknown","productId":"unknown","conditions":[]}`,
+		`{"id":`,
+		fmt.Sprintf(streamLine, "last"),
+	}, "\n")
+	req := httptest.NewRequest(http.MethodPost, "/calculate/stream", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/x-ndjson", rec.Header().Get("Content-Type"))
+
+	lines := strings.Split(strings.TrimSuffix(rec.Body.String(), "\n"), "\n")
+	require.Len(t, lines, 4)
+	results := make([]BatchResult, len(lines))
+	for i, line := range lines {
+		require.NoError(t, json.Unmarshal([]byte(line), &results[i]))
+	}
+
+	assert.Equal(t, "adsl", results[0].ID)
+	require.NotNil(t, results[0].Offer)
+	assert.Equal(t, 400.0, results[0].Offer.TotalCost.Cost)
+	assert.Empty(t, results[0].Offer.QuoteID)
+	assert.Empty(t, storage.quotes)
+	assert.Equal(t, &BatchError{Status: http.StatusNotFound, Message: "unknown product: unknown"}, results[1].Error)
+	assert.Equal(t, &BatchError{Status: http.StatusBadRequest, Message: "line 4: invalid request"}, results[2].Error)
+	assert.Equal(t, "last", results[3].ID)
+
+	req = httptest.NewRequest(http.MethodPost, "/calculate/stream", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusUnsupportedMediaType, rec.Code)
+}
+
+func TestCalculateStreamFullDuplex(t *testing.T) {
+	catalogs, err := NewCatalogStore(writeCatalog(t, map[string]interface{}{"gaming.json": product}), NewMemoryStorage())
+	require.NoError(t, err)
+	ts := httptest.NewServer(newServer(catalogs, nil).routes())
+	defer ts.Close()
+
+	bodyReader, bodyWriter := io.Pipe()
+	defer bodyWriter.Close()
+	req, err := http.NewRequest(http.MethodPost, ts.URL+"/calculate/stream", bodyReader)
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/x-ndjson")
+
+	go func() {
+		_, _ = fmt.Fprintln(bodyWriter, fmt.Sprintf(streamLine, "first"))
+	}()
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	lines := bufio.NewScanner(resp.Body)
+
+	// Each result arrives while the request body is still open.
+	for _, id := range []string{"first", "second"} {
+		if id != "first" {
+			_, err = fmt.Fprintln(bodyWriter, fmt.Sprintf(streamLine, id))
+			require.NoError(t, err)
+		}
+		require.True(t, lines.Scan(), lines.Err())
+		var result BatchResult
+		require.NoError(t, json.Unmarshal(lines.Bytes(), &result))
+		assert.Equal(t, id, result.ID)
+		require.NotNil(t, result.Offer)
+	}
+
+	require.NoError(t, bodyWriter.Close())
+	assert.False(t, lines.Scan())
+	assert.NoError(t, lines.Err())
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"strings"
+
+	"go-rti-testing/pkg/errors"
+)
+
+// ValidateProduct checks that the product can be calculated:
+// names are given and unique, price types, operators and rule values are valid
+// and every reference to a component points to an existing one.
+func ValidateProduct(product *Product) error {
+	if product.Name == "" {
+		return errors.BadRequest.New("product name is required")
+	}
+
+	switch strings.ToUpper(product.MainComponents) {
+	case "", MainComponentsAll, MainComponentsAny:
+	default:
+		return errors.BadRequest.Newf("invalid mainComponents: %s", product.MainComponents)
+	}
+
+	if err := validateRules(product.RuleApplicabilities); err != nil {
+		return errors.BadRequest.Wrap(err, "product rules")
+	}
+
+	names := make(map[string]bool, len(product.Components))
+	for i, component := range product.Components {
+		if component.Name == "" {
+			return errors.BadRequest.Newf("component %d: name is required", i)
+		}
+		if names[component.Name] {
+			return errors.BadRequest.Newf("component %s: duplicate name", component.Name)
+		}
+		names[component.Name] = true
+	}
+
+	for _, component := range product.Components {
+		if err := validateComponent(component, names); err != nil {
+			return errors.BadRequest.Wrapf(err, "component %s", component.Name)
+		}
+	}
+
+	for i, group := range product.Groups {
+		if group.Name == "" {
+			return errors.BadRequest.Newf("group %d: name is required", i)
+		}
+		if group.Min < 0 || group.Max < 0 || (group.Max > 0 && group.Min > group.Max) {
+			return errors.BadRequest.Newf("group %s: invalid min or max", group.Name)
+		}
+		if err := validateReferences(group.Components, names); err != nil {
+			return errors.BadRequest.Wrapf(err, "group %s", group.Name)
+		}
+	}
+
+	for i, bundle := range product.Bundles {
+		if bundle.Name == "" {
+			return errors.BadRequest.Newf("bundle %d: name is required", i)
+		}
+		if bundle.Discount < 0 || bundle.Discount > 100 || bundle.Amount < 0 {
+			return errors.BadRequest.Newf("bundle %s: invalid discount or amount", bundle.Name)
+		}
+		if len(bundle.Components) == 0 {
+			return errors.BadRequest.Newf("bundle %s: components are required", bundle.Name)
+		}
+		if err := validateReferences(bundle.Components, names); err != nil {
+			return errors.BadRequest.Wrapf(err, "bundle %s", bundle.Name)
+		}
+		if err := validateReferences(bundle.Targets, names); err != nil {
+			return errors.BadRequest.Wrapf(err, "bundle %s", bundle.Name)
+		}
+	}
+
+	return nil
+}
+
+func validateComponent(component Component, names map[string]bool) error {
+	if err := validateReferences(component.Requires, names); err != nil {
+		return err
+	}
+	if err := validateReferences(component.Excludes, names); err != nil {
+		return err
+	}
+
+	for i, price := range component.Prices {
+		switch strings.ToUpper(price.PriceType) {
+		case PriceTypeCost, PriceTypeDiscount:
+		default:
+			return errors.BadRequest.Newf("price %d: invalid priceType: %s", i, price.PriceType)
+		}
+		if price.Cost < 0 {
+			return errors.BadRequest.Newf("price %d: cost must not be negative", i)
+		}
+		if err := validateRules(price.RuleApplicabilities); err != nil {
+			return errors.BadRequest.Wrapf(err, "price %d", i)
+		}
+	}
+
+	return nil
+}
+
+func validateRules(rules []RuleApplicability) error {
+	for _, rule := range rules {
+		if rule.CodeName == "" {
+			return errors.BadRequest.New("rule codeName is required")
+		}
+
+		var err error
+		switch rule.Operator {
+		case OperatorEqual, OperatorStartsWith, OperatorEndsWith, OperatorContains:
+		case OperatorLessThanOrEqual, OperatorGreaterThanOrEqual:
+			_, err = parseFloat(rule.Value)
+		case OperatorMatches:
+			_, err = compilePattern(rule.Value)
+		default:
+			err = errors.BadRequest.Newf("invalid operator: %s", rule.Operator)
+		}
+		if err != nil {
+			return errors.BadRequest.Wrapf(err, "rule %s", rule.CodeName)
+		}
+	}
+
+	return nil
+}
+
+func validateReferences(references []string, names map[string]bool) error {
+	for _, name := range references {
+		if !names[name] {
+			return errors.BadRequest.Newf("unknown component: %s", name)
+		}
+	}
+	return nil
+}
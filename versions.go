@@ -0,0 +1,196 @@
+package main
+
+import (
+	"sort"
+	"time"
+
+	"go-rti-testing/pkg/errors"
+)
+
+// ProductVersion is an immutable version of a catalog product.
+// A version without the product records the removal of the product from the catalog.
+type ProductVersion struct {
+	ProductID string    `json:"productId" yaml:"productId"`
+	Version   int       `json:"version" yaml:"version"`
+	Hash      string    `json:"hash,omitempty" yaml:"hash,omitempty"`
+	CreatedAt time.Time `json:"createdAt" yaml:"createdAt"`
+	Product   *Product  `json:"product,omitempty" yaml:"product,omitempty"`
+}
+
+type productVersion struct {
+	ProductVersion
+	compiled *CompiledProduct
+}
+
+// Function appends versions of the products added, changed or removed
+// by the new catalog and stamps the compiled products with their versions.
+// Unchanged products keep the current version. New versions are persisted
+// before they become visible, unless the store is read-only.
+func (s *CatalogStore) recordVersions(catalog *Catalog) error {
+	s.historyMu.Lock()
+	defer s.historyMu.Unlock()
+
+	now := s.now()
+	var added []*productVersion
+
+	for _, id := range catalog.IDs() {
+		p := catalog.products[id]
+		if last := s.last(id); last != nil && last.Hash == p.hash {
+			p.compiled = last.compiled
+			continue
+		}
+
+		p.compiled.version = len(s.history[id]) + 1
+		added = append(added, &productVersion{
+			ProductVersion: ProductVersion{
+				ProductID: id,
+				Version:   p.compiled.version,
+				Hash:      p.hash,
+				CreatedAt: now,
+				Product:   p.product,
+			},
+			compiled: p.compiled,
+		})
+	}
+
+	ids := make([]string, 0, len(s.history))
+	for id := range s.history {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		if _, ok := catalog.products[id]; ok || s.last(id) == nil {
+			continue
+		}
+		added = append(added, &productVersion{
+			ProductVersion: ProductVersion{ProductID: id, Version: len(s.history[id]) + 1, CreatedAt: now},
+		})
+	}
+
+	for _, v := range added {
+		if !s.readOnly {
+			if err := s.storage.AppendVersion(v.ProductVersion); err != nil {
+				return errors.Wrapf(err, "store version %d of product %s", v.Version, v.ProductID)
+			}
+		}
+		s.history[v.ProductID] = append(s.history[v.ProductID], v)
+	}
+	return nil
+}
+
+// Function restores the version history from the storage.
+func (s *CatalogStore) loadVersions() error {
+	versions, err := s.storage.Versions()
+	if err != nil {
+		return errors.Wrap(err, "load product versions")
+	}
+
+	s.historyMu.Lock()
+	defer s.historyMu.Unlock()
+
+	s.history = make(map[string][]*productVersion)
+	for _, v := range versions {
+		if v.Version != len(s.history[v.ProductID])+1 {
+			return errors.Internal.Newf("stored version %d of product %s is out of order", v.Version, v.ProductID)
+		}
+
+		compiled := Compile(v.Product)
+		if compiled != nil {
+			compiled.version = v.Version
+		}
+		s.history[v.ProductID] = append(s.history[v.ProductID], &productVersion{ProductVersion: v, compiled: compiled})
+	}
+	return nil
+}
+
+// Function returns the catalog of the last versions of the products which were not removed.
+func (s *CatalogStore) lastCatalog() *Catalog {
+	s.historyMu.RLock()
+	defer s.historyMu.RUnlock()
+
+	catalog := &Catalog{products: make(map[string]*catalogProduct, len(s.history))}
+	for id := range s.history {
+		if last := s.last(id); last != nil {
+			catalog.products[id] = &catalogProduct{product: last.Product, compiled: last.compiled, hash: last.Hash}
+		}
+	}
+	return catalog
+}
+
+// Function returns the last version of the product if it is in the catalog.
+func (s *CatalogStore) last(id string) *productVersion {
+	versions := s.history[id]
+	if len(versions) == 0 || versions[len(versions)-1].compiled == nil {
+		return nil
+	}
+	return versions[len(versions)-1]
+}
+
+// Versions returns all versions of the product, the oldest first.
+func (s *CatalogStore) Versions(id string) ([]ProductVersion, error) {
+	if s == nil {
+		return nil, errors.NotFound.Newf("unknown product: %s", id)
+	}
+
+	s.historyMu.RLock()
+	defer s.historyMu.RUnlock()
+
+	history := s.history[id]
+	if len(history) == 0 {
+		return nil, errors.NotFound.Newf("unknown product: %s", id)
+	}
+
+	versions := make([]ProductVersion, 0, len(history))
+	for _, v := range history {
+		versions = append(versions, v.ProductVersion)
+	}
+	return versions, nil
+}
+
+// Version returns the compiled product of the version.
+func (s *CatalogStore) Version(id string, version int) (*CompiledProduct, error) {
+	if s == nil {
+		return nil, errors.NotFound.Newf("unknown product: %s", id)
+	}
+
+	s.historyMu.RLock()
+	defer s.historyMu.RUnlock()
+
+	history := s.history[id]
+	if len(history) == 0 {
+		return nil, errors.NotFound.Newf("unknown product: %s", id)
+	}
+	if version < 1 || version > len(history) {
+		return nil, errors.NotFound.Newf("unknown version %d of product %s", version, id)
+	}
+	if v := history[version-1]; v.compiled != nil {
+		return v.compiled, nil
+	}
+	return nil, errors.NotFound.Newf("version %d of product %s is a removal", version, id)
+}
+
+// AsOf returns the compiled product version that was current at the time.
+func (s *CatalogStore) AsOf(id string, at time.Time) (*CompiledProduct, error) {
+	if s == nil {
+		return nil, errors.NotFound.Newf("unknown product: %s", id)
+	}
+
+	s.historyMu.RLock()
+	defer s.historyMu.RUnlock()
+
+	history := s.history[id]
+	if len(history) == 0 {
+		return nil, errors.NotFound.Newf("unknown product: %s", id)
+	}
+
+	for i := len(history) - 1; i >= 0; i-- {
+		if history[i].CreatedAt.After(at) {
+			continue
+		}
+		if history[i].compiled == nil {
+			return nil, errors.NotFound.Newf("product %s was removed at %s", id, history[i].CreatedAt.Format(time.RFC3339))
+		}
+		return history[i].compiled, nil
+	}
+	return nil, errors.NotFound.Newf("product %s did not exist at %s", id, at.Format(time.RFC3339))
+}
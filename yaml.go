@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+
+	"go-rti-testing/pkg/errors"
+)
+
+// MarshalProductYAML encodes the product in YAML. Comments of the previous YAML
+// document are kept on the fields and list items which are still there.
+// A new document gets the product ID and name as the comment at the top.
+func MarshalProductYAML(product *Product, previous []byte) ([]byte, error) {
+	var node yaml.Node
+	if err := node.Encode(product); err != nil {
+		return nil, errors.Internal.Wrap(err, "encode product")
+	}
+
+	doc := &yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{&node}}
+	var old yaml.Node
+	if err := yaml.Unmarshal(previous, &old); err == nil {
+		mergeYAMLComments(doc, &old)
+	}
+	if len(previous) == 0 {
+		doc.HeadComment = fmt.Sprintf("Product %s: %s", product.ID, product.Name)
+	}
+
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(doc); err != nil {
+		return nil, errors.Internal.Wrap(err, "encode product")
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, errors.Internal.Wrap(err, "encode product")
+	}
+	return buf.Bytes(), nil
+}
+
+// Function copies the comments of the old node tree to the same places of the new one.
+// Mapping values are matched by key, list items by their name field or else by position.
+func mergeYAMLComments(node, old *yaml.Node) {
+	node.HeadComment, node.LineComment, node.FootComment = old.HeadComment, old.LineComment, old.FootComment
+	if node.Kind != old.Kind {
+		return
+	}
+
+	switch node.Kind {
+	case yaml.DocumentNode:
+		if len(node.Content) > 0 && len(old.Content) > 0 {
+			mergeYAMLComments(node.Content[0], old.Content[0])
+		}
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			for j := 0; j+1 < len(old.Content); j += 2 {
+				if node.Content[i].Value == old.Content[j].Value {
+					mergeYAMLComments(node.Content[i], old.Content[j])
+					mergeYAMLComments(node.Content[i+1], old.Content[j+1])
+					break
+				}
+			}
+		}
+	case yaml.SequenceNode:
+		for i, item := range node.Content {
+			if previous := yamlListItem(old, item, i); previous != nil {
+				mergeYAMLComments(item, previous)
+			}
+		}
+	}
+}
+
+// Function finds the old list item with the name of the item.
+// Items without a name are matched by position.
+func yamlListItem(old, item *yaml.Node, i int) *yaml.Node {
+	if name := yamlMappingValue(item, "name"); name != nil {
+		for _, previous := range old.Content {
+			if value := yamlMappingValue(previous, "name"); value != nil && value.Value == name.Value {
+				return previous
+			}
+		}
+		return nil
+	}
+	if i < len(old.Content) {
+		return old.Content[i]
+	}
+	return nil
+}
+
+func yamlMappingValue(node *yaml.Node, key string) *yaml.Node {
+	if node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// UnmarshalProductYAML decodes the product from YAML. Comments are allowed anywhere.
+func UnmarshalProductYAML(data []byte) (*Product, error) {
+	product := new(Product)
+	if err := yaml.Unmarshal(data, product); err != nil {
+		return nil, errors.BadRequest.Wrap(err, "decode product")
+	}
+	return product, nil
+}
+
+func isYAMLFile(ext string) bool {
+	return ext == ".yaml" || ext == ".yml"
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+const productYAML = `# Игровой тариф
+# Цены с 01.01.2021
+
+name: Игровой
+components:
+  - name: Интернет
+    isMain: true
+    prices:
+      # Базовая цена
+      - cost: 100
+        priceType: COST
+        ruleApplicabilities:
+          - codeName: technology # adsl или xpon
+            operator: EQ
+            value: adsl
+          - codeName: internetSpeed
+            operator: EQ
+            value: 10
+`
+
+func TestProductYAML(t *testing.T) {
+	product, err := UnmarshalProductYAML([]byte(productYAML))
+	require.NoError(t, err)
+	require.NoError(t, ValidateProduct(product))
+	assert.Equal(t, "10", product.Components[0].Prices[0].RuleApplicabilities[1].Value)
+
+	product.ID = "gaming"
+	data, err := MarshalProductYAML(product, nil)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(string(data), "# Product gaming: Игровой\n"), string(data))
+	assert.Contains(t, string(data), "ruleApplicabilities:")
+
+	decoded, err := UnmarshalProductYAML(data)
+	require.NoError(t, err)
+	assert.Equal(t, product, decoded)
+}
+
+func TestCatalogYAML(t *testing.T) {
+	dir := writeCatalog(t, nil)
+	path := filepath.Join(dir, "gaming.yaml")
+	require.NoError(t, ioutil.WriteFile(path, []byte(productYAML), 0644))
+
+	catalogs, err := NewCatalogStore(dir, NewMemoryStorage())
+	require.NoError(t, err)
+	handler := newServer(catalogs, nil).routes()
+
+	// Calculate with a YAML request and response.
+	req := httptest.NewRequest(http.MethodPost, "/calculate", strings.NewReader(`
+productId: gaming
+conditions:
+  - ruleName: technology
+    value: adsl
+  - ruleName: internetSpeed
+    value: 10
+`))
+	req.Header.Set("Content-Type", "application/yaml")
+	req.Header.Set("Accept", "application/yaml")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, "application/yaml", rec.Header().Get("Content-Type"))
+	var offer Offer
+	require.NoError(t, yaml.Unmarshal(rec.Body.Bytes(), &offer))
+	assert.Equal(t, "gaming", offer.ID)
+	assert.Equal(t, 100.0, offer.TotalCost.Cost)
+
+	// Replacing the product keeps the YAML file with its comments.
+	req = httptest.NewRequest(http.MethodPut, "/products/gaming", strings.NewReader(
+		strings.Replace(productYAML, "cost: 100", "cost: 120", 1)))
+	req.Header.Set("Content-Type", "application/yaml; charset=utf-8")
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	data, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(string(data), "# Игровой тариф\n# Цены с 01.01.2021\n"), string(data))
+	assert.Contains(t, string(data), "cost: 120")
+	assert.Contains(t, string(data), "# Базовая цена\n")
+	assert.Contains(t, string(data), "codeName: technology # adsl или xpon\n")
+
+	req = httptest.NewRequest(http.MethodPost, "/calculate", strings.NewReader("productId: gaming"))
+	req.Header.Set("Content-Type", "text/plain")
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusUnsupportedMediaType, rec.Code)
+}